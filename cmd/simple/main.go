@@ -0,0 +1,310 @@
+// Command simple runs and inspects programs written in Simple.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/compiler"
+	"github.com/avearmin/simple/internal/diagnostics"
+	"github.com/avearmin/simple/internal/evaluator"
+	"github.com/avearmin/simple/internal/format"
+	"github.com/avearmin/simple/internal/lexer"
+	"github.com/avearmin/simple/internal/object"
+	"github.com/avearmin/simple/internal/parser"
+	"github.com/avearmin/simple/internal/repl"
+	"github.com/avearmin/simple/internal/resolver"
+	"github.com/avearmin/simple/internal/token"
+	"github.com/avearmin/simple/internal/types"
+	"github.com/avearmin/simple/internal/vm"
+)
+
+const usage = `usage: simple <command> [arguments]
+
+commands:
+  run [-engine vm|eval] <file>  run a program
+  check [-types] <file>...      report syntax errors and undefined names
+  fmt [-w] <file>...            print programs in their canonical layout
+  tokens <file>                 print the tokens of a program
+  ast [-json] <file>            print the syntax tree of a program
+  repl                          start an interactive session
+`
+
+type command func(args []string, stdout, stderr io.Writer) int
+
+var commands = map[string]command{
+	"run":    runCommand,
+	"check":  checkCommand,
+	"fmt":    fmtCommand,
+	"tokens": tokensCommand,
+	"ast":    astCommand,
+	"repl":   replCommand,
+}
+
+func main() {
+	os.Exit(dispatch(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func dispatch(args []string, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "simple: unknown command '%s'\n\n%s", args[0], usage)
+		return 2
+	}
+
+	return cmd(args[1:], stdout, stderr)
+}
+
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	engine := flags.String("engine", "vm", "execute with the bytecode virtual machine (vm) or the tree-walking evaluator (eval)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprint(stderr, "usage: simple run [-engine vm|eval] <file>\n")
+		return 2
+	}
+	if *engine != "vm" && *engine != "eval" {
+		fmt.Fprintf(stderr, "simple: unknown engine '%s'\n", *engine)
+		return 2
+	}
+
+	filename := flags.Arg(0)
+	program, ok := checkFile(filename, false, stderr)
+	if !ok {
+		return 1
+	}
+
+	var err error
+	if *engine == "eval" {
+		_, err = evaluator.Eval(program, object.NewEnvironment(stdout))
+	} else {
+		var bytecode *compiler.Bytecode
+		bytecode, err = compiler.New().Compile(program)
+		if err == nil {
+			_, err = vm.New(bytecode, stdout).Run()
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", filename, err)
+		return 1
+	}
+
+	return 0
+}
+
+func checkCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	checkTypes := flags.Bool("types", false, "also infer types, and report values of the wrong type")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 1 {
+		fmt.Fprint(stderr, "usage: simple check [-types] <file>...\n")
+		return 2
+	}
+
+	status := 0
+	for _, filename := range flags.Args() {
+		if _, ok := checkFile(filename, *checkTypes, stderr); !ok {
+			status = 1
+		}
+	}
+
+	return status
+}
+
+func fmtCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write the result to each file instead of printing it")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 1 {
+		fmt.Fprint(stderr, "usage: simple fmt [-w] <file>...\n")
+		return 2
+	}
+
+	status := 0
+	for _, filename := range flags.Args() {
+		program, ok := parseFile(filename, stderr)
+		if !ok {
+			status = 1
+			continue
+		}
+
+		var b strings.Builder
+		if err := format.Fprint(&b, program); err != nil {
+			fmt.Fprintf(stderr, "simple: %s\n", err)
+			status = 1
+			continue
+		}
+
+		if !*write {
+			fmt.Fprint(stdout, b.String())
+			continue
+		}
+		if err := os.WriteFile(filename, []byte(b.String()), 0o644); err != nil {
+			fmt.Fprintf(stderr, "simple: %s\n", err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+func tokensCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprint(stderr, "usage: simple tokens <file>\n")
+		return 2
+	}
+
+	input, ok := readFile(args[0], stderr)
+	if !ok {
+		return 1
+	}
+
+	l := lexer.New(input)
+	for {
+		tok := l.NextToken()
+		for _, comment := range tok.Comments {
+			fmt.Fprintf(stdout, "%d:%d\tCOMMENT\t%q\n", comment.Line, comment.Col, comment.Text)
+		}
+		fmt.Fprintf(stdout, "%d:%d\t%s\t%q\n", tok.Line, tok.Col, tok.Type, tok.Literal)
+
+		if tok.Type == token.EOF {
+			return 0
+		}
+	}
+}
+
+func astCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the syntax tree as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprint(stderr, "usage: simple ast [-json] <file>\n")
+		return 2
+	}
+
+	program, ok := parseFile(flags.Arg(0), stderr)
+	if !ok {
+		return 1
+	}
+
+	var err error
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(program)
+	} else {
+		err = ast.Fprint(stdout, program)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "simple: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func replCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 0 {
+		fmt.Fprint(stderr, "usage: simple repl\n")
+		return 2
+	}
+
+	if err := repl.Start(os.Stdin, stdout); err != nil {
+		fmt.Fprintf(stderr, "simple: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func readFile(filename string, stderr io.Writer) (string, bool) {
+	input, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(stderr, "simple: %s\n", err)
+		return "", false
+	}
+	return string(input), true
+}
+
+// parseFile reads and parses filename, reporting every syntax error to
+// stderr.
+func parseFile(filename string, stderr io.Writer) (*ast.Program, bool) {
+	input, ok := readFile(filename, stderr)
+	if !ok {
+		return nil, false
+	}
+
+	program, err := parser.New(lexer.New(input)).ParseProgram()
+	if !report(filename, input, err, stderr) {
+		return nil, false
+	}
+
+	return program, true
+}
+
+// checkFile reads and parses filename, and resolves the names it uses, or
+// with checkTypes infers their types, reporting every error to stderr.
+func checkFile(filename string, checkTypes bool, stderr io.Writer) (*ast.Program, bool) {
+	input, ok := readFile(filename, stderr)
+	if !ok {
+		return nil, false
+	}
+
+	program, err := parser.New(lexer.New(input)).ParseProgram()
+	if !report(filename, input, err, stderr) {
+		return nil, false
+	}
+
+	if checkTypes {
+		_, err = types.Check(program)
+	} else {
+		_, err = resolver.Resolve(program)
+	}
+	if !report(filename, input, err, stderr) {
+		return nil, false
+	}
+
+	return program, true
+}
+
+// report renders the diagnostics in err, or prints err if it is any other
+// error, and reports whether there was no error.
+func report(filename, input string, err error, stderr io.Writer) bool {
+	if err == nil {
+		return true
+	}
+
+	diags, ok := err.(diagnostics.List)
+	if !ok {
+		fmt.Fprintf(stderr, "%s: %s\n", filename, err)
+		return false
+	}
+
+	for _, d := range diags {
+		diagnostics.Render(stderr, filename, input, d)
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, input string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	ok := write("ok.smp", "(:= x 5) (= x (+ x 1))")
+	bad := write("bad.smp", "(:= x")
+	failing := write("failing.smp", "(:= x (/ 1 0))")
+	twoErrors := write("two_errors.smp", "(:= x)\n(:= 1 y)")
+	commented := write("commented.smp", "; x is five\n(:= x 5)")
+	undefined := write("undefined.smp", "(:= x 5)\n(print y)")
+	mistyped := write("mistyped.smp", "(:= x (+ true 1))")
+	printing := write("printing.smp", "(:= x 5)\n(print \"x is\" x)")
+
+	tests := map[string]struct {
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		"no command":      {args: []string{}, wantCode: 2, wantStderr: "usage: simple"},
+		"unknown command": {args: []string{"build"}, wantCode: 2, wantStderr: "unknown command 'build'"},
+		"run":             {args: []string{"run", ok}, wantCode: 0},
+		"run with eval":   {args: []string{"run", "-engine", "eval", ok}, wantCode: 0},
+		"run output": {
+			args: []string{"run", printing}, wantCode: 0, wantStdout: "x is 5\n",
+		},
+		"run output with eval": {
+			args: []string{"run", "-engine", "eval", printing}, wantCode: 0, wantStdout: "x is 5\n",
+		},
+		"run unknown engine": {
+			args: []string{"run", "-engine", "jit", ok}, wantCode: 2, wantStderr: "unknown engine 'jit'",
+		},
+		"run runtime error": {
+			args: []string{"run", failing}, wantCode: 1, wantStderr: "failing.smp: division by zero on line 1 col 7",
+		},
+		"run parse error": {args: []string{"run", bad}, wantCode: 1, wantStderr: " --> " + bad + ":1:4\n"},
+		"run missing file": {
+			args: []string{"run", filepath.Join(dir, "missing.smp")}, wantCode: 1, wantStderr: "missing.smp",
+		},
+		"check": {args: []string{"check", ok}, wantCode: 0},
+		"check reports every error": {
+			args: []string{"check", twoErrors}, wantCode: 1,
+			wantStderr: "error[P001]: expected 'DELIMITER', but got ')'\n" +
+				" --> " + twoErrors + ":1:5\n" +
+				"  |\n" +
+				"1 | (:= x)\n" +
+				"  |      ^\n" +
+				"error[P001]: expected 'IDENT', but got 'INT'\n" +
+				" --> " + twoErrors + ":2:4\n" +
+				"  |\n" +
+				"2 | (:= 1 y)\n" +
+				"  |     ^\n",
+		},
+		"run undefined name": {
+			args: []string{"run", undefined}, wantCode: 1, wantStderr: "error[R001]: undefined identifier 'y'\n",
+		},
+		"check undefined name": {
+			args: []string{"check", undefined}, wantCode: 1,
+			wantStderr: "error[R001]: undefined identifier 'y'\n" +
+				" --> " + undefined + ":2:7\n" +
+				"  |\n" +
+				"2 | (print y)\n" +
+				"  |        ^\n",
+		},
+		"check types":         {args: []string{"check", "-types", ok}, wantCode: 0},
+		"check without types": {args: []string{"check", mistyped}, wantCode: 0},
+		"check type error": {
+			args: []string{"check", "-types", mistyped}, wantCode: 1,
+			wantStderr: "error[T001]: mismatched types 'bool' and 'int' for '+'\n" +
+				" --> " + mistyped + ":1:7\n",
+		},
+		"check with failure": {args: []string{"check", ok, bad}, wantCode: 1, wantStderr: " --> " + bad + ":1:4\n"},
+		"fmt": {
+			args: []string{"fmt", ok, commented}, wantCode: 0,
+			wantStdout: "(:= x 5)\n(= x (+ x 1))\n; x is five\n(:= x 5)\n",
+		},
+		"fmt parse error": {args: []string{"fmt", bad}, wantCode: 1, wantStderr: " --> " + bad + ":1:4\n"},
+		"tokens": {
+			args: []string{"tokens", ok}, wantCode: 0, wantStdout: "1:0\t(\t\"(\"\n1:1\t:=\t\":=\"\n",
+		},
+		"tokens with comments": {
+			args: []string{"tokens", commented}, wantCode: 0,
+			wantStdout: "1:0\tDELIMITER\t\"\"\n1:0\tCOMMENT\t\"; x is five\"\n2:0\t(\t\"(\"\n",
+		},
+		"check with comments": {args: []string{"check", commented}, wantCode: 0},
+		"ast json": {
+			args: []string{"ast", "-json", ok}, wantCode: 0,
+			wantStdout: "{\n  \"version\": 1,\n  \"kind\": \"Program\",\n",
+		},
+		"ast": {
+			args: []string{"ast", ok}, wantCode: 0,
+			wantStdout: "Program\n  Statements:\n    AssignStatement := \":=\" 1:1\n      Name: Atom IDENT \"x\" 1:4\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			code := dispatch(tc.args, &stdout, &stderr)
+			if code != tc.wantCode {
+				t.Fatalf("expected exit code %d, but got %d (stderr: %q)", tc.wantCode, code, stderr.String())
+			}
+			if !strings.HasPrefix(stdout.String(), tc.wantStdout) {
+				t.Fatalf("expected stdout to start with %q, but got %q", tc.wantStdout, stdout.String())
+			}
+			if !strings.Contains(stderr.String(), tc.wantStderr) {
+				t.Fatalf("expected stderr to contain %q, but got %q", tc.wantStderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestFmtWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.smp")
+	if err := os.WriteFile(path, []byte("(while true  (break))"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := dispatch([]string{"fmt", "-w", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, but got %d (stderr: %q)", code, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected nothing on stdout, but got %q", stdout.String())
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(while true\n    (break))\n"; string(got) != want {
+		t.Fatalf("got=%q, want=%q", got, want)
+	}
+}
+
+func TestReport(t *testing.T) {
+	tests := map[string]struct {
+		err        error
+		want       bool
+		wantStderr string
+	}{
+		"no error":    {err: nil, want: true},
+		"other error": {err: errors.New("boom"), want: false, wantStderr: "main.smp: boom\n"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			if got := report("main.smp", "", tc.err, &stderr); got != tc.want {
+				t.Fatalf("got=%t, want=%t", got, tc.want)
+			}
+			if stderr.String() != tc.wantStderr {
+				t.Fatalf("expected stderr %q, but got %q", tc.wantStderr, stderr.String())
+			}
+		})
+	}
+}
@@ -5,6 +5,22 @@ import "github.com/avearmin/simple/internal/token"
 type Node interface {
 	TokenLiteral() string
 	TokenType() token.Type
+
+	// Span returns the range of the input the node was parsed from, which
+	// for a node in parentheses runs from the '(' to the ')'.
+	Span() Span
+}
+
+// Span is a range of the input, from the first character of a node up to the
+// position just past its last.
+type Span struct {
+	Start token.Position
+	End   token.Position
+}
+
+// spanOf returns the span from the start of first to the end of last.
+func spanOf(first, last token.Token) Span {
+	return Span{Start: first.Pos(), End: last.End()}
 }
 
 type Statement interface {
@@ -19,6 +35,10 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+
+	// Comments are all the comments of the program, in the order they
+	// appear in the input.
+	Comments []token.Comment
 }
 
 func (p *Program) TokenLiteral() string {
@@ -29,25 +49,48 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) TokenType() token.Type {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].TokenType()
+	} else {
+		return ""
+	}
+}
+
+// Span returns the span from the start of the first statement to the end of
+// the last, which is empty if there are no statements.
+func (p *Program) Span() Span {
+	if len(p.Statements) == 0 {
+		return Span{}
+	}
+	return Span{Start: p.Statements[0].Span().Start, End: p.Statements[len(p.Statements)-1].Span().End}
+}
+
 type AssignStatement struct {
-	Token token.Token
-	Name  Atom
-	Value Expression
+	Token  token.Token
+	LParen token.Token
+	RParen token.Token
+	Name   Atom
+	Value  Expression
 }
 
 func (as AssignStatement) statementNode()        {}
 func (as AssignStatement) TokenLiteral() string  { return as.Token.Literal }
 func (as AssignStatement) TokenType() token.Type { return as.Token.Type }
+func (as AssignStatement) Span() Span            { return spanOf(as.LParen, as.RParen) }
 
 type ReassignStatement struct {
-	Token token.Token
-	Name  Atom
-	Value Expression
+	Token  token.Token
+	LParen token.Token
+	RParen token.Token
+	Name   Atom
+	Value  Expression
 }
 
 func (rs ReassignStatement) statementNode()        {}
 func (rs ReassignStatement) TokenLiteral() string  { return rs.Token.Literal }
 func (rs ReassignStatement) TokenType() token.Type { return rs.Token.Type }
+func (rs ReassignStatement) Span() Span            { return spanOf(rs.LParen, rs.RParen) }
 
 type ElifBlock struct {
 	Token      token.Token
@@ -62,6 +105,8 @@ type ElseBlock struct {
 
 type ConditionalStatement struct {
 	Token        token.Token
+	LParen       token.Token
+	RParen       token.Token
 	IfCondition  Expression
 	IfStatements []Statement
 	ElifBlocks   []ElifBlock
@@ -71,9 +116,12 @@ type ConditionalStatement struct {
 func (cs ConditionalStatement) statementNode()        {}
 func (cs ConditionalStatement) TokenLiteral() string  { return cs.Token.Literal }
 func (cs ConditionalStatement) TokenType() token.Type { return cs.Token.Type }
+func (cs ConditionalStatement) Span() Span            { return spanOf(cs.LParen, cs.RParen) }
 
 type FunctionAssignStatement struct {
 	Token      token.Token
+	LParen     token.Token
+	RParen     token.Token
 	Name       Atom
 	Params     []Atom
 	Statements []Statement
@@ -82,27 +130,90 @@ type FunctionAssignStatement struct {
 func (fas FunctionAssignStatement) statementNode()        {}
 func (fas FunctionAssignStatement) TokenLiteral() string  { return fas.Token.Literal }
 func (fas FunctionAssignStatement) TokenType() token.Type { return fas.Token.Type }
+func (fas FunctionAssignStatement) Span() Span            { return spanOf(fas.LParen, fas.RParen) }
 
 type ReturnStatement struct {
-	Token token.Token
-	Value Expression
+	Token  token.Token
+	LParen token.Token
+	RParen token.Token
+	Value  Expression
 }
 
 func (rs ReturnStatement) statementNode()        {}
 func (rs ReturnStatement) TokenLiteral() string  { return rs.Token.Literal }
 func (rs ReturnStatement) TokenType() token.Type { return rs.Token.Type }
+func (rs ReturnStatement) Span() Span            { return spanOf(rs.LParen, rs.RParen) }
 
+// ForLoopStatement is a loop with a C style header. Initalizer, Condition and
+// Update are nil when their slot is left empty, and a missing Condition
+// always holds.
 type ForLoopStatement struct {
 	Token      token.Token
-	Initalizer AssignStatement
-	Condition  BinaryExpression
-	Update     ReassignStatement
+	LParen     token.Token
+	RParen     token.Token
+	Initalizer Statement
+	Condition  Expression
+	Update     Statement
 	Statements []Statement
 }
 
 func (fls ForLoopStatement) statementNode()        {}
 func (fls ForLoopStatement) TokenLiteral() string  { return fls.Token.Literal }
 func (fls ForLoopStatement) TokenType() token.Type { return fls.Token.Type }
+func (fls ForLoopStatement) Span() Span            { return spanOf(fls.LParen, fls.RParen) }
+
+// ForEachStatement runs its body once for each item of Iterable, with Names
+// bound to the values of the item. A single name is bound to the element of a
+// list or the key of a map, and two names to an index and element or a key
+// and value.
+type ForEachStatement struct {
+	Token      token.Token
+	LParen     token.Token
+	RParen     token.Token
+	Names      []Atom
+	Iterable   Expression
+	Statements []Statement
+}
+
+func (fes ForEachStatement) statementNode()        {}
+func (fes ForEachStatement) TokenLiteral() string  { return fes.Token.Literal }
+func (fes ForEachStatement) TokenType() token.Type { return fes.Token.Type }
+func (fes ForEachStatement) Span() Span            { return spanOf(fes.LParen, fes.RParen) }
+
+type WhileStatement struct {
+	Token      token.Token
+	LParen     token.Token
+	RParen     token.Token
+	Condition  Expression
+	Statements []Statement
+}
+
+func (ws WhileStatement) statementNode()        {}
+func (ws WhileStatement) TokenLiteral() string  { return ws.Token.Literal }
+func (ws WhileStatement) TokenType() token.Type { return ws.Token.Type }
+func (ws WhileStatement) Span() Span            { return spanOf(ws.LParen, ws.RParen) }
+
+type BreakStatement struct {
+	Token  token.Token
+	LParen token.Token
+	RParen token.Token
+}
+
+func (bs BreakStatement) statementNode()        {}
+func (bs BreakStatement) TokenLiteral() string  { return bs.Token.Literal }
+func (bs BreakStatement) TokenType() token.Type { return bs.Token.Type }
+func (bs BreakStatement) Span() Span            { return spanOf(bs.LParen, bs.RParen) }
+
+type ContinueStatement struct {
+	Token  token.Token
+	LParen token.Token
+	RParen token.Token
+}
+
+func (cs ContinueStatement) statementNode()        {}
+func (cs ContinueStatement) TokenLiteral() string  { return cs.Token.Literal }
+func (cs ContinueStatement) TokenType() token.Type { return cs.Token.Type }
+func (cs ContinueStatement) Span() Span            { return spanOf(cs.LParen, cs.RParen) }
 
 type Atom struct {
 	Token token.Token
@@ -112,9 +223,12 @@ type Atom struct {
 func (a Atom) expressionNode()       {}
 func (a Atom) TokenLiteral() string  { return a.Token.Literal }
 func (a Atom) TokenType() token.Type { return a.Token.Type }
+func (a Atom) Span() Span            { return spanOf(a.Token, a.Token) }
 
 type BinaryExpression struct {
 	Token  token.Token
+	LParen token.Token
+	RParen token.Token
 	First  Expression
 	Second Expression
 }
@@ -122,13 +236,116 @@ type BinaryExpression struct {
 func (be BinaryExpression) expressionNode()       {}
 func (be BinaryExpression) TokenLiteral() string  { return be.Token.Literal }
 func (be BinaryExpression) TokenType() token.Type { return be.Token.Type }
+func (be BinaryExpression) Span() Span            { return spanOf(be.LParen, be.RParen) }
 
+// FunctionLiteral is an anonymous function, created each time the literal is
+// evaluated.
+type FunctionLiteral struct {
+	Token      token.Token
+	LParen     token.Token
+	RParen     token.Token
+	Params     []Atom
+	Statements []Statement
+}
+
+func (fl FunctionLiteral) expressionNode()       {}
+func (fl FunctionLiteral) TokenLiteral() string  { return fl.Token.Literal }
+func (fl FunctionLiteral) TokenType() token.Type { return fl.Token.Type }
+func (fl FunctionLiteral) Span() Span            { return spanOf(fl.LParen, fl.RParen) }
+
+type ListLiteral struct {
+	Token    token.Token
+	LParen   token.Token
+	RParen   token.Token
+	Elements []Expression
+}
+
+func (ll ListLiteral) expressionNode()       {}
+func (ll ListLiteral) TokenLiteral() string  { return ll.Token.Literal }
+func (ll ListLiteral) TokenType() token.Type { return ll.Token.Type }
+func (ll ListLiteral) Span() Span            { return spanOf(ll.LParen, ll.RParen) }
+
+// LogicalExpression is an && or || applied to two or more operands, which
+// are evaluated from left to right only until the result is known.
+type LogicalExpression struct {
+	Token    token.Token
+	LParen   token.Token
+	RParen   token.Token
+	Operands []Expression
+}
+
+func (le LogicalExpression) expressionNode()       {}
+func (le LogicalExpression) TokenLiteral() string  { return le.Token.Literal }
+func (le LogicalExpression) TokenType() token.Type { return le.Token.Type }
+func (le LogicalExpression) Span() Span            { return spanOf(le.LParen, le.RParen) }
+
+type UnaryExpression struct {
+	Token   token.Token
+	LParen  token.Token
+	RParen  token.Token
+	Operand Expression
+}
+
+func (ue UnaryExpression) expressionNode()       {}
+func (ue UnaryExpression) TokenLiteral() string  { return ue.Token.Literal }
+func (ue UnaryExpression) TokenType() token.Type { return ue.Token.Type }
+func (ue UnaryExpression) Span() Span            { return spanOf(ue.LParen, ue.RParen) }
+
+// FnCall calls the function Callee evaluates to. Token is the first token of
+// Callee, which is where errors about the call are reported.
 type FnCall struct {
 	Token     token.Token
-	Arguments []Atom
+	LParen    token.Token
+	RParen    token.Token
+	Callee    Expression
+	Arguments []Expression
 }
 
 func (fc FnCall) expressionNode()       {}
 func (fc FnCall) statementNode()        {}
 func (fc FnCall) TokenLiteral() string  { return fc.Token.Literal }
 func (fc FnCall) TokenType() token.Type { return fc.Token.Type }
+func (fc FnCall) Span() Span            { return spanOf(fc.LParen, fc.RParen) }
+
+// TokenOf returns the token a node was built from, which is where errors
+// about that node are reported.
+func TokenOf(node Node) token.Token {
+	switch node := node.(type) {
+	case AssignStatement:
+		return node.Token
+	case ReassignStatement:
+		return node.Token
+	case ConditionalStatement:
+		return node.Token
+	case FunctionAssignStatement:
+		return node.Token
+	case ReturnStatement:
+		return node.Token
+	case ForLoopStatement:
+		return node.Token
+	case ForEachStatement:
+		return node.Token
+	case WhileStatement:
+		return node.Token
+	case BreakStatement:
+		return node.Token
+	case ContinueStatement:
+		return node.Token
+	case Atom:
+		return node.Token
+	case BinaryExpression:
+		return node.Token
+	case FunctionLiteral:
+		return node.Token
+	case ListLiteral:
+		return node.Token
+	case LogicalExpression:
+		return node.Token
+	case UnaryExpression:
+		return node.Token
+	case FnCall:
+		return node.Token
+	default:
+		return token.Token{}
+	}
+}
@@ -0,0 +1,589 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/avearmin/simple/internal/token"
+)
+
+// JSONVersion is the version of the schema programs are written in as JSON.
+// It changes only when the schema changes in a way older readers cannot
+// handle.
+const JSONVersion = 1
+
+// In the JSON schema each node is an object like this one:
+//
+//	{
+//	  "kind": "AssignStatement",
+//	  "token": {"type": ":=", "literal": ":=", "span": {...}},
+//	  "span": {"start": {"offset": 0, "line": 1, "col": 0}, "end": {...}},
+//	  "children": {"name": {...}, "value": {...}}
+//	}
+//
+// The kind is the name of the node's type. Each child is a node, a list of
+// nodes, or null for an empty slot of a for loop header or a missing else
+// block. The elif and else blocks of a conditional are nodes of the kinds
+// ElifBlock and ElseBlock. An Atom has its value in "value", and the Program
+// at the root has the schema's "version" and all of the program's
+// "comments".
+type jsonNode[Child any] struct {
+	Version  int              `json:"version,omitempty"`
+	Kind     string           `json:"kind"`
+	Token    *jsonToken       `json:"token,omitempty"`
+	Value    *string          `json:"value,omitempty"`
+	Span     jsonSpan         `json:"span"`
+	Children map[string]Child `json:"children,omitempty"`
+	Comments []jsonComment    `json:"comments,omitempty"`
+}
+
+// The children of a node are built as Go values when writing, and read one
+// at a time, as the kind of the node says they should be, when reading.
+type (
+	encodedNode = jsonNode[any]
+	decodedNode = jsonNode[json.RawMessage]
+)
+
+type jsonToken struct {
+	Type     token.Type    `json:"type"`
+	Literal  string        `json:"literal"`
+	Span     jsonSpan      `json:"span"`
+	Comments []jsonComment `json:"comments,omitempty"`
+}
+
+type jsonSpan struct {
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+type jsonPosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Col    int `json:"col"`
+}
+
+type jsonComment struct {
+	Text string `json:"text"`
+	Line int    `json:"line"`
+	Col  int    `json:"col"`
+}
+
+// MarshalJSON writes the program in the JSON schema.
+func (p *Program) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encode(p))
+}
+
+// UnmarshalJSON reads a program written in the JSON schema. The parentheses
+// around each node are given back from its span, but any comments they
+// carried are only kept in the program's Comments.
+func (p *Program) UnmarshalJSON(data []byte) error {
+	var n decodedNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	if n.Kind != "Program" {
+		return fmt.Errorf("expected a Program, but got a node of kind '%s'", n.Kind)
+	}
+	if n.Version != JSONVersion {
+		return fmt.Errorf("cannot read version %d of the syntax tree schema, only version %d", n.Version, JSONVersion)
+	}
+
+	var d decoder
+	program := Program{Statements: d.statements(&n, "statements"), Comments: decodeComments(n.Comments)}
+	if d.err != nil {
+		return d.err
+	}
+
+	*p = program
+	return nil
+}
+
+func encode(node Node) *encodedNode {
+	switch n := node.(type) {
+	case *Program:
+		e := newEncodedNode("Program", nil, n.Span())
+		e.Version = JSONVersion
+		e.Comments = encodeComments(n.Comments)
+		e.Children["statements"] = encodeStatements(n.Statements)
+		return e
+	case AssignStatement:
+		e := newEncodedNode("AssignStatement", &n.Token, n.Span())
+		e.Children["name"] = encode(n.Name)
+		e.Children["value"] = encodeOptional(n.Value)
+		return e
+	case ReassignStatement:
+		e := newEncodedNode("ReassignStatement", &n.Token, n.Span())
+		e.Children["name"] = encode(n.Name)
+		e.Children["value"] = encodeOptional(n.Value)
+		return e
+	case ConditionalStatement:
+		e := newEncodedNode("ConditionalStatement", &n.Token, n.Span())
+		e.Children["condition"] = encodeOptional(n.IfCondition)
+		e.Children["statements"] = encodeStatements(n.IfStatements)
+
+		elifs := make([]*encodedNode, len(n.ElifBlocks))
+		for i, elif := range n.ElifBlocks {
+			elifs[i] = newEncodedNode("ElifBlock", &elif.Token, blockSpan(elif.Token, elif.Condition, elif.Statements))
+			elifs[i].Children["condition"] = encodeOptional(elif.Condition)
+			elifs[i].Children["statements"] = encodeStatements(elif.Statements)
+		}
+		e.Children["elifs"] = elifs
+
+		e.Children["else"] = nil
+		if n.ElseBlock.Token.Type == token.Else {
+			elseBlock := newEncodedNode("ElseBlock", &n.ElseBlock.Token, blockSpan(n.ElseBlock.Token, nil, n.ElseBlock.Statements))
+			elseBlock.Children["statements"] = encodeStatements(n.ElseBlock.Statements)
+			e.Children["else"] = elseBlock
+		}
+		return e
+	case FunctionAssignStatement:
+		e := newEncodedNode("FunctionAssignStatement", &n.Token, n.Span())
+		e.Children["name"] = encode(n.Name)
+		e.Children["params"] = encodeAtoms(n.Params)
+		e.Children["statements"] = encodeStatements(n.Statements)
+		return e
+	case ReturnStatement:
+		e := newEncodedNode("ReturnStatement", &n.Token, n.Span())
+		e.Children["value"] = encodeOptional(n.Value)
+		return e
+	case ForLoopStatement:
+		e := newEncodedNode("ForLoopStatement", &n.Token, n.Span())
+		e.Children["initializer"] = encodeOptional(n.Initalizer)
+		e.Children["condition"] = encodeOptional(n.Condition)
+		e.Children["update"] = encodeOptional(n.Update)
+		e.Children["statements"] = encodeStatements(n.Statements)
+		return e
+	case ForEachStatement:
+		e := newEncodedNode("ForEachStatement", &n.Token, n.Span())
+		e.Children["names"] = encodeAtoms(n.Names)
+		e.Children["iterable"] = encodeOptional(n.Iterable)
+		e.Children["statements"] = encodeStatements(n.Statements)
+		return e
+	case WhileStatement:
+		e := newEncodedNode("WhileStatement", &n.Token, n.Span())
+		e.Children["condition"] = encodeOptional(n.Condition)
+		e.Children["statements"] = encodeStatements(n.Statements)
+		return e
+	case BreakStatement:
+		return newEncodedNode("BreakStatement", &n.Token, n.Span())
+	case ContinueStatement:
+		return newEncodedNode("ContinueStatement", &n.Token, n.Span())
+	case Atom:
+		e := newEncodedNode("Atom", &n.Token, n.Span())
+		e.Value = &n.Value
+		return e
+	case BinaryExpression:
+		e := newEncodedNode("BinaryExpression", &n.Token, n.Span())
+		e.Children["first"] = encodeOptional(n.First)
+		e.Children["second"] = encodeOptional(n.Second)
+		return e
+	case FunctionLiteral:
+		e := newEncodedNode("FunctionLiteral", &n.Token, n.Span())
+		e.Children["params"] = encodeAtoms(n.Params)
+		e.Children["statements"] = encodeStatements(n.Statements)
+		return e
+	case ListLiteral:
+		e := newEncodedNode("ListLiteral", &n.Token, n.Span())
+		e.Children["elements"] = encodeExpressions(n.Elements)
+		return e
+	case LogicalExpression:
+		e := newEncodedNode("LogicalExpression", &n.Token, n.Span())
+		e.Children["operands"] = encodeExpressions(n.Operands)
+		return e
+	case UnaryExpression:
+		e := newEncodedNode("UnaryExpression", &n.Token, n.Span())
+		e.Children["operand"] = encodeOptional(n.Operand)
+		return e
+	case FnCall:
+		e := newEncodedNode("FnCall", &n.Token, n.Span())
+		e.Children["callee"] = encodeOptional(n.Callee)
+		e.Children["arguments"] = encodeExpressions(n.Arguments)
+		return e
+	default:
+		panic(fmt.Sprintf("ast: cannot write a %T as JSON", node))
+	}
+}
+
+func newEncodedNode(kind string, tok *token.Token, span Span) *encodedNode {
+	n := &encodedNode{Kind: kind, Span: encodeSpan(span), Children: map[string]any{}}
+	if tok != nil {
+		n.Token = &jsonToken{
+			Type:     tok.Type,
+			Literal:  tok.Literal,
+			Span:     encodeSpan(Span{Start: tok.Pos(), End: tok.End()}),
+			Comments: encodeComments(tok.Comments),
+		}
+	}
+	return n
+}
+
+// encodeOptional encodes node, or returns nil, which is written as null, if
+// there is no node.
+func encodeOptional(node Node) any {
+	if node == nil {
+		return nil
+	}
+	return encode(node)
+}
+
+func encodeStatements(stmts []Statement) []*encodedNode {
+	encoded := make([]*encodedNode, len(stmts))
+	for i, stmt := range stmts {
+		encoded[i] = encode(stmt)
+	}
+	return encoded
+}
+
+func encodeExpressions(exps []Expression) []*encodedNode {
+	encoded := make([]*encodedNode, len(exps))
+	for i, exp := range exps {
+		encoded[i] = encode(exp)
+	}
+	return encoded
+}
+
+func encodeAtoms(atoms []Atom) []*encodedNode {
+	encoded := make([]*encodedNode, len(atoms))
+	for i, atom := range atoms {
+		encoded[i] = encode(atom)
+	}
+	return encoded
+}
+
+func encodeSpan(span Span) jsonSpan {
+	return jsonSpan{Start: jsonPosition(span.Start), End: jsonPosition(span.End)}
+}
+
+func encodeComments(comments []token.Comment) []jsonComment {
+	encoded := make([]jsonComment, len(comments))
+	for i, comment := range comments {
+		encoded[i] = jsonComment(comment)
+	}
+	return encoded
+}
+
+// blockSpan returns the span of an elif or else block, which runs from its
+// keyword to the end of its last statement or its condition.
+func blockSpan(keyword token.Token, condition Expression, stmts []Statement) Span {
+	span := spanOf(keyword, keyword)
+	if condition != nil {
+		span.End = condition.Span().End
+	}
+	if len(stmts) > 0 {
+		span.End = stmts[len(stmts)-1].Span().End
+	}
+	return span
+}
+
+// decoder reads nodes from JSON. It keeps the first error it comes to, after
+// which it reads nothing more and returns zero values.
+type decoder struct {
+	err error
+}
+
+func (d *decoder) errorf(format string, a ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, a...)
+	}
+}
+
+// child reads the child of n called name, which is nil if it is null.
+func (d *decoder) child(n *decodedNode, name string) *decodedNode {
+	raw, ok := n.Children[name]
+	if d.err != nil || !ok {
+		return nil
+	}
+
+	var child *decodedNode
+	if err := json.Unmarshal(raw, &child); err != nil {
+		d.errorf("reading the %s of a %s: %w", name, n.Kind, err)
+	}
+	return child
+}
+
+// list reads the list of children of n called name.
+func (d *decoder) list(n *decodedNode, name string) []*decodedNode {
+	raw, ok := n.Children[name]
+	if d.err != nil || !ok {
+		return nil
+	}
+
+	var children []*decodedNode
+	if err := json.Unmarshal(raw, &children); err != nil {
+		d.errorf("reading the %s of a %s: %w", name, n.Kind, err)
+	}
+	return children
+}
+
+func (d *decoder) statement(n *decodedNode, name string) Statement {
+	child := d.child(n, name)
+	if child == nil {
+		d.errorf("a %s is missing its %s", n.Kind, name)
+		return nil
+	}
+	return d.asStatement(child)
+}
+
+func (d *decoder) optionalStatement(n *decodedNode, name string) Statement {
+	if child := d.child(n, name); child != nil {
+		return d.asStatement(child)
+	}
+	return nil
+}
+
+func (d *decoder) asStatement(n *decodedNode) Statement {
+	node := d.node(n)
+	if node == nil {
+		return nil
+	}
+	stmt, ok := node.(Statement)
+	if !ok {
+		d.errorf("expected a statement, but got a node of kind '%s' on line %d col %d", n.Kind, n.Span.Start.Line, n.Span.Start.Col)
+	}
+	return stmt
+}
+
+func (d *decoder) expression(n *decodedNode, name string) Expression {
+	child := d.child(n, name)
+	if child == nil {
+		d.errorf("a %s is missing its %s", n.Kind, name)
+		return nil
+	}
+	return d.asExpression(child)
+}
+
+func (d *decoder) optionalExpression(n *decodedNode, name string) Expression {
+	if child := d.child(n, name); child != nil {
+		return d.asExpression(child)
+	}
+	return nil
+}
+
+func (d *decoder) asExpression(n *decodedNode) Expression {
+	node := d.node(n)
+	if node == nil {
+		return nil
+	}
+	exp, ok := node.(Expression)
+	if !ok {
+		d.errorf("expected an expression, but got a node of kind '%s' on line %d col %d", n.Kind, n.Span.Start.Line, n.Span.Start.Col)
+	}
+	return exp
+}
+
+func (d *decoder) atom(n *decodedNode, name string) Atom {
+	child := d.child(n, name)
+	if child == nil {
+		d.errorf("a %s is missing its %s", n.Kind, name)
+		return Atom{}
+	}
+	return d.asAtom(child)
+}
+
+func (d *decoder) asAtom(n *decodedNode) Atom {
+	atom, ok := d.node(n).(Atom)
+	if !ok {
+		d.errorf("expected an Atom, but got a node of kind '%s' on line %d col %d", n.Kind, n.Span.Start.Line, n.Span.Start.Col)
+	}
+	return atom
+}
+
+func (d *decoder) statements(n *decodedNode, name string) []Statement {
+	children := d.list(n, name)
+	stmts := make([]Statement, 0, len(children))
+	for _, child := range children {
+		stmts = append(stmts, d.asStatement(child))
+	}
+	return stmts
+}
+
+func (d *decoder) expressions(n *decodedNode, name string) []Expression {
+	children := d.list(n, name)
+	exps := make([]Expression, 0, len(children))
+	for _, child := range children {
+		exps = append(exps, d.asExpression(child))
+	}
+	return exps
+}
+
+func (d *decoder) atoms(n *decodedNode, name string) []Atom {
+	children := d.list(n, name)
+	atoms := make([]Atom, 0, len(children))
+	for _, child := range children {
+		atoms = append(atoms, d.asAtom(child))
+	}
+	return atoms
+}
+
+// node reads n as the node its kind names.
+func (d *decoder) node(n *decodedNode) Node {
+	if d.err != nil {
+		return nil
+	}
+	if n == nil {
+		d.errorf("expected a node, but got null")
+		return nil
+	}
+
+	tok := decodeToken(n.Token)
+	lparen, rparen := decodeParens(n.Span)
+
+	switch n.Kind {
+	case "AssignStatement":
+		return AssignStatement{Token: tok, LParen: lparen, RParen: rparen, Name: d.atom(n, "name"), Value: d.expression(n, "value")}
+	case "ReassignStatement":
+		return ReassignStatement{Token: tok, LParen: lparen, RParen: rparen, Name: d.atom(n, "name"), Value: d.expression(n, "value")}
+	case "ConditionalStatement":
+		stmt := ConditionalStatement{
+			Token:        tok,
+			LParen:       lparen,
+			RParen:       rparen,
+			IfCondition:  d.expression(n, "condition"),
+			IfStatements: d.statements(n, "statements"),
+			ElifBlocks:   []ElifBlock{},
+		}
+		for _, elif := range d.list(n, "elifs") {
+			if elif == nil || elif.Kind != "ElifBlock" {
+				d.errorf("expected an ElifBlock in the elifs of a ConditionalStatement")
+				return nil
+			}
+			stmt.ElifBlocks = append(stmt.ElifBlocks, ElifBlock{
+				Token:      decodeToken(elif.Token),
+				Condition:  d.expression(elif, "condition"),
+				Statements: d.statements(elif, "statements"),
+			})
+		}
+		if elseBlock := d.child(n, "else"); elseBlock != nil {
+			if elseBlock.Kind != "ElseBlock" {
+				d.errorf("expected an ElseBlock as the else of a ConditionalStatement, but got a node of kind '%s'", elseBlock.Kind)
+				return nil
+			}
+			stmt.ElseBlock = ElseBlock{Token: decodeToken(elseBlock.Token), Statements: d.statements(elseBlock, "statements")}
+		}
+		return stmt
+	case "FunctionAssignStatement":
+		return FunctionAssignStatement{
+			Token:      tok,
+			LParen:     lparen,
+			RParen:     rparen,
+			Name:       d.atom(n, "name"),
+			Params:     d.atoms(n, "params"),
+			Statements: d.statements(n, "statements"),
+		}
+	case "ReturnStatement":
+		return ReturnStatement{Token: tok, LParen: lparen, RParen: rparen, Value: d.expression(n, "value")}
+	case "ForLoopStatement":
+		return ForLoopStatement{
+			Token:      tok,
+			LParen:     lparen,
+			RParen:     rparen,
+			Initalizer: d.optionalStatement(n, "initializer"),
+			Condition:  d.optionalExpression(n, "condition"),
+			Update:     d.optionalStatement(n, "update"),
+			Statements: d.statements(n, "statements"),
+		}
+	case "ForEachStatement":
+		return ForEachStatement{
+			Token:      tok,
+			LParen:     lparen,
+			RParen:     rparen,
+			Names:      d.atoms(n, "names"),
+			Iterable:   d.expression(n, "iterable"),
+			Statements: d.statements(n, "statements"),
+		}
+	case "WhileStatement":
+		return WhileStatement{
+			Token:      tok,
+			LParen:     lparen,
+			RParen:     rparen,
+			Condition:  d.expression(n, "condition"),
+			Statements: d.statements(n, "statements"),
+		}
+	case "BreakStatement":
+		return BreakStatement{Token: tok, LParen: lparen, RParen: rparen}
+	case "ContinueStatement":
+		return ContinueStatement{Token: tok, LParen: lparen, RParen: rparen}
+	case "Atom":
+		if n.Value == nil {
+			d.errorf("an Atom is missing its value")
+			return nil
+		}
+		return Atom{Token: tok, Value: *n.Value}
+	case "BinaryExpression":
+		return BinaryExpression{
+			Token:  tok,
+			LParen: lparen,
+			RParen: rparen,
+			First:  d.expression(n, "first"),
+			Second: d.expression(n, "second"),
+		}
+	case "FunctionLiteral":
+		return FunctionLiteral{
+			Token:      tok,
+			LParen:     lparen,
+			RParen:     rparen,
+			Params:     d.atoms(n, "params"),
+			Statements: d.statements(n, "statements"),
+		}
+	case "ListLiteral":
+		return ListLiteral{Token: tok, LParen: lparen, RParen: rparen, Elements: d.expressions(n, "elements")}
+	case "LogicalExpression":
+		return LogicalExpression{Token: tok, LParen: lparen, RParen: rparen, Operands: d.expressions(n, "operands")}
+	case "UnaryExpression":
+		return UnaryExpression{Token: tok, LParen: lparen, RParen: rparen, Operand: d.expression(n, "operand")}
+	case "FnCall":
+		return FnCall{
+			Token:     tok,
+			LParen:    lparen,
+			RParen:    rparen,
+			Callee:    d.expression(n, "callee"),
+			Arguments: d.expressions(n, "arguments"),
+		}
+	default:
+		d.errorf("unknown kind of node '%s' on line %d col %d", n.Kind, n.Span.Start.Line, n.Span.Start.Col)
+		return nil
+	}
+}
+
+func decodeToken(tok *jsonToken) token.Token {
+	if tok == nil {
+		return token.Token{}
+	}
+	return token.Token{
+		Type:      tok.Type,
+		Literal:   tok.Literal,
+		Line:      tok.Span.Start.Line,
+		Col:       tok.Span.Start.Col,
+		Offset:    tok.Span.Start.Offset,
+		EndOffset: tok.Span.End.Offset,
+		EndLine:   tok.Span.End.Line,
+		EndCol:    tok.Span.End.Col,
+		Comments:  decodeComments(tok.Comments),
+	}
+}
+
+// decodeParens returns the parentheses at either end of span.
+func decodeParens(span jsonSpan) (token.Token, token.Token) {
+	start, end := span.Start, span.End
+	lparen := token.Token{
+		Type: token.LParen, Literal: "(", Line: start.Line, Col: start.Col,
+		Offset: start.Offset, EndOffset: start.Offset + 1, EndLine: start.Line, EndCol: start.Col + 1,
+	}
+	rparen := token.Token{
+		Type: token.RParen, Literal: ")", Line: end.Line, Col: end.Col - 1,
+		Offset: end.Offset - 1, EndOffset: end.Offset, EndLine: end.Line, EndCol: end.Col,
+	}
+	return lparen, rparen
+}
+
+func decodeComments(comments []jsonComment) []token.Comment {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	decoded := make([]token.Comment, len(comments))
+	for i, comment := range comments {
+		decoded[i] = token.Comment(comment)
+	}
+	return decoded
+}
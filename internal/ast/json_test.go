@@ -0,0 +1,157 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/avearmin/simple/internal/ast"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := map[string]struct {
+		input string
+	}{
+		"statements": {
+			input: "(:= x 1)\n(= x (+ x 2.5))\n(return (! (&& a b c)))",
+		},
+		"conditional": {
+			input: "(if a (f) elif b (g) elif c (h) else (i))\n(if a (f))\n(if a (f) else )",
+		},
+		"functions": {
+			input: "(fn f x y (return ((fn (z) (return z)) (list x y))))\n(:= g (fn ()))",
+		},
+		"loops": {
+			input: "(for (:= i 0) (< i 3) (= i (+ i 1)) (f))\n(for () () () (break))\n(for k v in m (continue))\n(while x)",
+		},
+		"strings": {
+			input: `(print "a\"b\n" nil true)`,
+		},
+		"empty program": {
+			input: "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			program := parse(t, test.input)
+
+			data, err := json.Marshal(program)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got ast.Program
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(&got, program) {
+				t.Fatalf("the program read back differs from the one written:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestJSONKeepsComments(t *testing.T) {
+	program := parse(t, "; first\n(:= x 1) ; one\n(while x\n    #| body |#\n    (f))\n; last")
+
+	data, err := json.Marshal(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got ast.Program
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got.Comments, program.Comments) {
+		t.Fatalf("got=%+v, want=%+v", got.Comments, program.Comments)
+	}
+	if formatProgram(t, &got) != formatProgram(t, program) {
+		t.Fatalf("got=\n%s\nwant=\n%s", formatProgram(t, &got), formatProgram(t, program))
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	program := parse(t, "(f x)")
+
+	data, err := json.Marshal(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every position is on the first line, where the offset and column
+	// are the same.
+	span := func(start, end int) string {
+		return fmt.Sprintf(`{"start":{"offset":%d,"line":1,"col":%d},"end":{"offset":%d,"line":1,"col":%d}}`, start, start, end, end)
+	}
+	want := `{"version":1,"kind":"Program","span":` + span(0, 5) + `,"children":{"statements":[` +
+		`{"kind":"FnCall","token":{"type":"IDENT","literal":"f","span":` + span(1, 2) + `},"span":` + span(0, 5) + `,` +
+		`"children":{"arguments":[` +
+		`{"kind":"Atom","token":{"type":"IDENT","literal":"x","span":` + span(3, 4) + `},"value":"x","span":` + span(3, 4) + `}` +
+		`],"callee":` +
+		`{"kind":"Atom","token":{"type":"IDENT","literal":"f","span":` + span(1, 2) + `},"value":"f","span":` + span(1, 2) + `}` +
+		`}}]}}`
+
+	if string(data) != want {
+		t.Fatalf("got=\n%s\nwant=\n%s", data, want)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	atom := `{"kind":"Atom","value":"x","span":{}}`
+	program := func(stmt string) string {
+		return `{"version":1,"kind":"Program","span":{},"children":{"statements":[` + stmt + `]}}`
+	}
+
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"not a program": {
+			input: atom,
+			want:  "expected a Program, but got a node of kind 'Atom'",
+		},
+		"unknown version": {
+			input: `{"version":2,"kind":"Program","span":{}}`,
+			want:  "cannot read version 2 of the syntax tree schema, only version 1",
+		},
+		"unknown kind": {
+			input: program(`{"kind":"GotoStatement","span":{"start":{"line":3,"col":4}}}`),
+			want:  "unknown kind of node 'GotoStatement' on line 3 col 4",
+		},
+		"expression as a statement": {
+			input: program(atom),
+			want:  "expected a statement, but got a node of kind 'Atom' on line 0 col 0",
+		},
+		"missing child": {
+			input: program(`{"kind":"ReturnStatement","span":{}}`),
+			want:  "a ReturnStatement is missing its value",
+		},
+		"statement as a name": {
+			input: program(`{"kind":"AssignStatement","span":{},"children":{"name":{"kind":"BreakStatement","span":{}},"value":` + atom + `}}`),
+			want:  "expected an Atom, but got a node of kind 'BreakStatement' on line 0 col 0",
+		},
+		"child of the wrong shape": {
+			input: program(`{"kind":"ListLiteral","span":{},"children":{"elements":` + atom + `}}`),
+			want:  "reading the elements of a ListLiteral",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got ast.Program
+			err := json.Unmarshal([]byte(test.input), &got)
+			if err == nil {
+				t.Fatalf("expected an error, but reading succeeded")
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("expected an error containing %q, but got %q", test.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/avearmin/simple/internal/token"
+)
+
+var (
+	tokenType    = reflect.TypeOf(token.Token{})
+	commentsType = reflect.TypeOf([]token.Comment{})
+)
+
+// Fprint writes node to w as an indented tree, one node per line. Each line
+// holds the node's kind, the type and literal of its token, and the token's
+// line and column.
+func Fprint(w io.Writer, node any) error {
+	p := printer{w: w}
+	p.print("", reflect.ValueOf(node), 0)
+	return p.err
+}
+
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(depth int, format string, a ...any) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, "%s%s\n", strings.Repeat("  ", depth), fmt.Sprintf(format, a...))
+}
+
+func (p *printer) print(label string, v reflect.Value, depth int) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			p.printf(depth, "%snil", label)
+			return
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		p.printf(depth, "%s%v", label, v.Interface())
+		return
+	}
+
+	header := v.Type().Name()
+	if tok := v.FieldByName("Token"); tok.IsValid() && tok.Type() == tokenType {
+		t := tok.Interface().(token.Token)
+		header = fmt.Sprintf("%s %s %q %d:%d", header, t.Type, t.Literal, t.Line, t.Col)
+	}
+	p.printf(depth, "%s%s", label, header)
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := v.Field(i)
+
+		if !field.IsExported() || field.Type == tokenType || field.Type == commentsType || field.Type.Kind() == reflect.String {
+			continue
+		}
+
+		switch value.Kind() {
+		case reflect.Slice:
+			if value.Len() == 0 {
+				continue
+			}
+			p.printf(depth+1, "%s:", field.Name)
+			for j := 0; j < value.Len(); j++ {
+				p.print("", value.Index(j), depth+2)
+			}
+		case reflect.Struct:
+			if tok := value.FieldByName("Token"); tok.IsValid() && tok.Type() == tokenType && tok.Interface().(token.Token).Type == "" {
+				continue
+			}
+			p.print(field.Name+": ", value, depth+1)
+		default:
+			p.print(field.Name+": ", value, depth+1)
+		}
+	}
+}
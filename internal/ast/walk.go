@@ -0,0 +1,329 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is called by Walk for each node it comes to. If
+// the Visitor w it returns is not nil, Walk visits each of the node's
+// children with w, and then calls w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the syntax tree under node in depth first order, in the
+// order the nodes appear in the input. It starts by calling v.Visit(node).
+//
+// The conditions and statements of the elif and else blocks of a
+// ConditionalStatement, and the header of a ForLoopStatement, are children of
+// the statement. Empty slots of a header are skipped.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case AssignStatement:
+		Walk(v, n.Name)
+		walkExpression(v, n.Value)
+	case ReassignStatement:
+		Walk(v, n.Name)
+		walkExpression(v, n.Value)
+	case ConditionalStatement:
+		walkExpression(v, n.IfCondition)
+		walkStatements(v, n.IfStatements)
+		for _, elif := range n.ElifBlocks {
+			walkExpression(v, elif.Condition)
+			walkStatements(v, elif.Statements)
+		}
+		walkStatements(v, n.ElseBlock.Statements)
+	case FunctionAssignStatement:
+		Walk(v, n.Name)
+		walkAtoms(v, n.Params)
+		walkStatements(v, n.Statements)
+	case ReturnStatement:
+		walkExpression(v, n.Value)
+	case ForLoopStatement:
+		walkStatement(v, n.Initalizer)
+		walkExpression(v, n.Condition)
+		walkStatement(v, n.Update)
+		walkStatements(v, n.Statements)
+	case ForEachStatement:
+		walkAtoms(v, n.Names)
+		walkExpression(v, n.Iterable)
+		walkStatements(v, n.Statements)
+	case WhileStatement:
+		walkExpression(v, n.Condition)
+		walkStatements(v, n.Statements)
+	case BinaryExpression:
+		walkExpression(v, n.First)
+		walkExpression(v, n.Second)
+	case FunctionLiteral:
+		walkAtoms(v, n.Params)
+		walkStatements(v, n.Statements)
+	case ListLiteral:
+		walkExpressions(v, n.Elements)
+	case LogicalExpression:
+		walkExpressions(v, n.Operands)
+	case UnaryExpression:
+		walkExpression(v, n.Operand)
+	case FnCall:
+		walkExpression(v, n.Callee)
+		walkExpressions(v, n.Arguments)
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatement(v Visitor, stmt Statement) {
+	if stmt != nil {
+		Walk(v, stmt)
+	}
+}
+
+func walkExpression(v Visitor, exp Expression) {
+	if exp != nil {
+		Walk(v, exp)
+	}
+}
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, stmt := range stmts {
+		walkStatement(v, stmt)
+	}
+}
+
+func walkExpressions(v Visitor, exps []Expression) {
+	for _, exp := range exps {
+		walkExpression(v, exp)
+	}
+}
+
+func walkAtoms(v Visitor, atoms []Atom) {
+	for _, atom := range atoms {
+		Walk(v, atom)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the syntax tree under node in the order Walk does,
+// calling f(node) for each node. If f returns true, Inspect goes on to the
+// node's children, and then calls f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Transform rebuilds the syntax tree under node from the bottom up, calling
+// f with each node once its children have been transformed, and putting the
+// node f returns in its place. Returning the node it was given keeps it.
+//
+// A node in a list of statements or expressions, or in the header of a
+// ForLoopStatement, is removed if f returns nil for it. Elsewhere, f must
+// return a node that can take the place of the one it was given: a Statement
+// for a statement, an Expression for an expression, and an Atom for a name
+// or parameter. Transform panics if it does not.
+//
+// The tree under node is not changed; the parts of it that are rebuilt are
+// copied.
+func Transform(node Node, f func(Node) Node) Node {
+	t := transformer(f)
+	return t.node(node)
+}
+
+type transformer func(Node) Node
+
+func (t transformer) node(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		program := *n
+		program.Statements = t.statements(n.Statements)
+		return t(&program)
+	case AssignStatement:
+		n.Name = t.atom(n.Name)
+		n.Value = t.expression(n.Value)
+		return t(n)
+	case ReassignStatement:
+		n.Name = t.atom(n.Name)
+		n.Value = t.expression(n.Value)
+		return t(n)
+	case ConditionalStatement:
+		n.IfCondition = t.expression(n.IfCondition)
+		n.IfStatements = t.statements(n.IfStatements)
+		if n.ElifBlocks != nil {
+			elifBlocks := make([]ElifBlock, len(n.ElifBlocks))
+			for i, elif := range n.ElifBlocks {
+				elif.Condition = t.expression(elif.Condition)
+				elif.Statements = t.statements(elif.Statements)
+				elifBlocks[i] = elif
+			}
+			n.ElifBlocks = elifBlocks
+		}
+		n.ElseBlock.Statements = t.statements(n.ElseBlock.Statements)
+		return t(n)
+	case FunctionAssignStatement:
+		n.Name = t.atom(n.Name)
+		n.Params = t.atoms(n.Params)
+		n.Statements = t.statements(n.Statements)
+		return t(n)
+	case ReturnStatement:
+		n.Value = t.expression(n.Value)
+		return t(n)
+	case ForLoopStatement:
+		n.Initalizer = t.optionalStatement(n.Initalizer)
+		n.Condition = t.optionalExpression(n.Condition)
+		n.Update = t.optionalStatement(n.Update)
+		n.Statements = t.statements(n.Statements)
+		return t(n)
+	case ForEachStatement:
+		n.Names = t.atoms(n.Names)
+		n.Iterable = t.expression(n.Iterable)
+		n.Statements = t.statements(n.Statements)
+		return t(n)
+	case WhileStatement:
+		n.Condition = t.expression(n.Condition)
+		n.Statements = t.statements(n.Statements)
+		return t(n)
+	case BinaryExpression:
+		n.First = t.expression(n.First)
+		n.Second = t.expression(n.Second)
+		return t(n)
+	case FunctionLiteral:
+		n.Params = t.atoms(n.Params)
+		n.Statements = t.statements(n.Statements)
+		return t(n)
+	case ListLiteral:
+		n.Elements = t.expressions(n.Elements)
+		return t(n)
+	case LogicalExpression:
+		n.Operands = t.expressions(n.Operands)
+		return t(n)
+	case UnaryExpression:
+		n.Operand = t.expression(n.Operand)
+		return t(n)
+	case FnCall:
+		n.Callee = t.expression(n.Callee)
+		n.Arguments = t.expressions(n.Arguments)
+		return t(n)
+	default:
+		return t(node)
+	}
+}
+
+// statement transforms a statement that must not be removed.
+func (t transformer) statement(stmt Statement) Statement {
+	if stmt == nil {
+		return nil
+	}
+
+	replacement := t.optionalStatement(stmt)
+	if replacement == nil {
+		panic(fmt.Sprintf("ast.Transform: the statement %T cannot be removed", stmt))
+	}
+	return replacement
+}
+
+// optionalStatement transforms a statement that is removed if f returns nil
+// for it.
+func (t transformer) optionalStatement(stmt Statement) Statement {
+	if stmt == nil {
+		return nil
+	}
+
+	node := t.node(stmt)
+	if node == nil {
+		return nil
+	}
+	replacement, ok := node.(Statement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Transform: %T cannot take the place of the statement %T", node, stmt))
+	}
+	return replacement
+}
+
+// expression transforms an expression that must not be removed. A slot that
+// is empty, such as the value of a return without one, stays empty.
+func (t transformer) expression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+
+	replacement := t.optionalExpression(exp)
+	if replacement == nil {
+		panic(fmt.Sprintf("ast.Transform: the expression %T cannot be removed", exp))
+	}
+	return replacement
+}
+
+// optionalExpression transforms an expression that is removed if f returns
+// nil for it.
+func (t transformer) optionalExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+
+	node := t.node(exp)
+	if node == nil {
+		return nil
+	}
+	replacement, ok := node.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Transform: %T cannot take the place of the expression %T", node, exp))
+	}
+	return replacement
+}
+
+func (t transformer) atom(atom Atom) Atom {
+	replacement, ok := t.node(atom).(Atom)
+	if !ok {
+		panic(fmt.Sprintf("ast.Transform: only an Atom can take the place of the name '%s'", atom.Value))
+	}
+	return replacement
+}
+
+func (t transformer) statements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+
+	transformed := make([]Statement, 0, len(stmts))
+	for _, stmt := range stmts {
+		if stmt = t.optionalStatement(stmt); stmt != nil {
+			transformed = append(transformed, stmt)
+		}
+	}
+	return transformed
+}
+
+func (t transformer) expressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+
+	transformed := make([]Expression, 0, len(exps))
+	for _, exp := range exps {
+		if exp = t.optionalExpression(exp); exp != nil {
+			transformed = append(transformed, exp)
+		}
+	}
+	return transformed
+}
+
+func (t transformer) atoms(atoms []Atom) []Atom {
+	if atoms == nil {
+		return nil
+	}
+
+	transformed := make([]Atom, len(atoms))
+	for i, atom := range atoms {
+		transformed[i] = t.atom(atom)
+	}
+	return transformed
+}
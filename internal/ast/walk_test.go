@@ -0,0 +1,285 @@
+package ast_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/format"
+	"github.com/avearmin/simple/internal/lexer"
+	"github.com/avearmin/simple/internal/parser"
+	"github.com/avearmin/simple/internal/token"
+)
+
+func TestInspect(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"assignment": {
+			input: "(:= x (+ 1 y))",
+			want:  ":= x + 1 y",
+		},
+		"elif and else blocks": {
+			input: "(if a (f) elif b (g) else (h))",
+			want:  "if a f f b g g h h",
+		},
+		"for loop header": {
+			input: "(for (:= i 0) (< i n) (= i (+ i 1)) (f i))",
+			want:  "for := i 0 < i n = i + i 1 f f i",
+		},
+		"empty header slots": {
+			input: "(for () () () (break))",
+			want:  "for break",
+		},
+		"functions": {
+			input: "(fn f x (return (fn (y) (return (list x y)))))",
+			want:  "fn f x return fn y return list x y",
+		},
+		"for each": {
+			input: "(for k v in (keys m) (while (! done) (continue)))",
+			want:  "for k v keys keys m while ! done continue",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			program := parse(t, test.input)
+
+			var got []string
+			ast.Inspect(program, func(node ast.Node) bool {
+				if node != nil && node != ast.Node(program) {
+					got = append(got, node.TokenLiteral())
+				}
+				return true
+			})
+
+			if strings.Join(got, " ") != test.want {
+				t.Fatalf("got=%q, want=%q", strings.Join(got, " "), test.want)
+			}
+		})
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	program := parse(t, "(:= x (+ 1 2))\n(fn f (return (+ 3 4)))")
+
+	var got []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if _, ok := node.(ast.FunctionAssignStatement); ok {
+			return false
+		}
+		if atom, ok := node.(ast.Atom); ok && atom.Token.Type == token.Int {
+			got = append(got, atom.Value)
+		}
+		return true
+	})
+
+	if want := "1 2"; strings.Join(got, " ") != want {
+		t.Fatalf("got=%q, want=%q", strings.Join(got, " "), want)
+	}
+}
+
+// depthVisitor records the deepest level of the tree it is taken to.
+type depthVisitor struct {
+	depth    int
+	maxDepth *int
+}
+
+func (v depthVisitor) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		return nil
+	}
+	*v.maxDepth = max(*v.maxDepth, v.depth)
+	return depthVisitor{depth: v.depth + 1, maxDepth: v.maxDepth}
+}
+
+func TestWalk(t *testing.T) {
+	program := parse(t, "(:= x 1)\n(if a (while b (= x (+ x 1))))")
+
+	var maxDepth int
+	ast.Walk(depthVisitor{maxDepth: &maxDepth}, program)
+
+	// Program, ConditionalStatement, WhileStatement, ReassignStatement,
+	// BinaryExpression and Atom.
+	if maxDepth != 5 {
+		t.Fatalf("expected a depth of 5, but got %d", maxDepth)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	tests := map[string]struct {
+		input     string
+		transform func(ast.Node) ast.Node
+		want      string
+	}{
+		"rename": {
+			input:     "(fn f x (:= y x) (for x in y (print x)))",
+			transform: rename("x", "z"),
+			want:      "(fn f z\n    (:= y z)\n    (for z in y\n        (print z)))\n",
+		},
+		"fold constants": {
+			input:     "(:= x (* (+ 1 2) (- 10 y)))\n(for (:= i (+ 0 0)) (< i (* 2 5)) () (f))",
+			transform: foldConstants,
+			want:      "(:= x (* 3 (- 10 y)))\n(for (:= i 0) (< i 10) ()\n    (f))\n",
+		},
+		"remove statements from elif and else blocks": {
+			input:     "(if a (print 1) (f) elif b (print 2) (g) else (print 3) (h))",
+			transform: removeCalls("print"),
+			want:      "(if a\n    (f)\nelif b\n    (g)\nelse\n    (h))\n",
+		},
+		"remove a for loop header slot": {
+			input:     "(for (print 0) c (print 1) (f))",
+			transform: removeCalls("print"),
+			want:      "(for () c ()\n    (f))\n",
+		},
+		"remove arguments": {
+			input:     "(f 1 (print 2) 3)",
+			transform: removeCalls("print"),
+			want:      "(f 1 3)\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			program := parse(t, test.input)
+			before := formatProgram(t, program)
+
+			transformed := ast.Transform(program, test.transform).(*ast.Program)
+
+			if got := formatProgram(t, transformed); got != test.want {
+				t.Fatalf("got=\n%s\nwant=\n%s", got, test.want)
+			}
+			if after := formatProgram(t, program); after != before {
+				t.Fatalf("the original tree was changed from\n%s\nto\n%s", before, after)
+			}
+		})
+	}
+}
+
+func TestTransformPanicsOnMismatch(t *testing.T) {
+	tests := map[string]struct {
+		input     string
+		transform func(ast.Node) ast.Node
+	}{
+		"statement in place of a name": {
+			input: "(:= x 1)",
+			transform: func(node ast.Node) ast.Node {
+				if atom, ok := node.(ast.Atom); ok && atom.Value == "x" {
+					return ast.BreakStatement{}
+				}
+				return node
+			},
+		},
+		"removed operand": {
+			input:     "(:= x (+ 1 (print 2)))",
+			transform: removeCalls("print"),
+		},
+		"removed value": {
+			input:     "(:= x (print 1))",
+			transform: removeCalls("print"),
+		},
+		"removed callee": {
+			input:     "((print 1) 2)",
+			transform: removeCalls("print"),
+		},
+		"removed condition": {
+			input:     "(while (print 1) (f))",
+			transform: removeCalls("print"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			program := parse(t, test.input)
+
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected Transform to panic")
+				}
+			}()
+
+			ast.Transform(program, test.transform)
+		})
+	}
+}
+
+func rename(from, to string) func(ast.Node) ast.Node {
+	return func(node ast.Node) ast.Node {
+		if atom, ok := node.(ast.Atom); ok && atom.Token.Type == token.Ident && atom.Value == from {
+			atom.Value = to
+			atom.Token.Literal = to
+			return atom
+		}
+		return node
+	}
+}
+
+func foldConstants(node ast.Node) ast.Node {
+	exp, ok := node.(ast.BinaryExpression)
+	if !ok {
+		return node
+	}
+
+	first, firstOk := intValue(exp.First)
+	second, secondOk := intValue(exp.Second)
+	if !firstOk || !secondOk {
+		return node
+	}
+
+	var value int
+	switch exp.Token.Type {
+	case token.Add:
+		value = first + second
+	case token.Subtract:
+		value = first - second
+	case token.Multiply:
+		value = first * second
+	default:
+		return node
+	}
+
+	literal := strconv.Itoa(value)
+	return ast.Atom{Token: token.Token{Type: token.Int, Literal: literal}, Value: literal}
+}
+
+func intValue(exp ast.Expression) (int, bool) {
+	atom, ok := exp.(ast.Atom)
+	if !ok || atom.Token.Type != token.Int {
+		return 0, false
+	}
+	value, err := strconv.Atoi(atom.Value)
+	return value, err == nil
+}
+
+func removeCalls(name string) func(ast.Node) ast.Node {
+	return func(node ast.Node) ast.Node {
+		if call, ok := node.(ast.FnCall); ok {
+			if callee, ok := call.Callee.(ast.Atom); ok && callee.Value == name {
+				return nil
+			}
+		}
+		return node
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	program, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+func formatProgram(t *testing.T, program *ast.Program) string {
+	t.Helper()
+
+	var b strings.Builder
+	if err := format.Fprint(&b, program); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
@@ -0,0 +1,250 @@
+package code
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpNil
+	OpTrue
+	OpFalse
+	OpPop
+
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpModulo
+
+	OpEquals
+	OpNotEquals
+	OpLessThan
+	OpGreaterThan
+	OpLessThanOrEquals
+	OpGreaterThanOrEquals
+	OpNot
+
+	OpJump
+	OpJumpNotTrue
+	OpJumpFalseOrPop
+	OpJumpTrueOrPop
+
+	OpGetGlobal
+	OpSetGlobal
+	OpReassignGlobal
+
+	OpDefineLocal
+	OpGetLocal
+	OpSetLocal
+	OpGetLocalCell
+
+	OpGetFree
+	OpSetFree
+	OpGetFreeCell
+
+	OpGetBuiltin
+
+	OpList
+
+	OpIter
+	OpIterNext
+
+	OpClosure
+	OpCall
+	OpReturnValue
+	OpReturn
+)
+
+// Definition describes how an Opcode is printed and how wide each of its
+// operands is in bytes.
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpNil:      {"OpNil", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:      {"OpAdd", []int{}},
+	OpSubtract: {"OpSubtract", []int{}},
+	OpMultiply: {"OpMultiply", []int{}},
+	OpDivide:   {"OpDivide", []int{}},
+	OpModulo:   {"OpModulo", []int{}},
+
+	OpEquals:              {"OpEquals", []int{}},
+	OpNotEquals:           {"OpNotEquals", []int{}},
+	OpLessThan:            {"OpLessThan", []int{}},
+	OpGreaterThan:         {"OpGreaterThan", []int{}},
+	OpLessThanOrEquals:    {"OpLessThanOrEquals", []int{}},
+	OpGreaterThanOrEquals: {"OpGreaterThanOrEquals", []int{}},
+	OpNot:                 {"OpNot", []int{}},
+
+	OpJump:           {"OpJump", []int{2}},
+	OpJumpNotTrue:    {"OpJumpNotTrue", []int{2}},
+	OpJumpFalseOrPop: {"OpJumpFalseOrPop", []int{2}},
+	OpJumpTrueOrPop:  {"OpJumpTrueOrPop", []int{2}},
+
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpReassignGlobal: {"OpReassignGlobal", []int{2}},
+
+	OpDefineLocal:  {"OpDefineLocal", []int{1}},
+	OpGetLocal:     {"OpGetLocal", []int{1}},
+	OpSetLocal:     {"OpSetLocal", []int{1}},
+	OpGetLocalCell: {"OpGetLocalCell", []int{1}},
+
+	OpGetFree:     {"OpGetFree", []int{1}},
+	OpSetFree:     {"OpSetFree", []int{1}},
+	OpGetFreeCell: {"OpGetFreeCell", []int{1}},
+
+	OpGetBuiltin: {"OpGetBuiltin", []int{1}},
+
+	OpList: {"OpList", []int{2}},
+
+	OpIter:     {"OpIter", []int{1}},
+	OpIterNext: {"OpIterNext", []int{2}},
+
+	OpClosure:     {"OpClosure", []int{2, 1}},
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes op and its operands as a single instruction.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, width := range def.OperandWidths {
+		instructionLen += width
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, operand := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(operand))
+		case 1:
+			instruction[offset] = byte(operand)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction described by def, and
+// reports how many bytes they took up.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+// String disassembles the instructions, one per line, each prefixed with its
+// offset.
+func (ins Instructions) String() string {
+	var builder strings.Builder
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			builder.WriteString(fmt.Sprintf("ERROR: %s\n", err))
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		builder.WriteString(fmt.Sprintf("%04d %s\n", i, formatInstruction(def, operands)))
+
+		i += 1 + read
+	}
+
+	return builder.String()
+}
+
+func formatInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), len(def.OperandWidths))
+	}
+
+	parts := []string{def.Name}
+	for _, operand := range operands {
+		parts = append(parts, fmt.Sprintf("%d", operand))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Position maps the instruction starting at Offset back to the line and
+// column of the source token it was compiled from.
+type Position struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+// SourceMap holds Positions in increasing Offset order.
+type SourceMap []Position
+
+// Lookup finds the position of the instruction that contains offset.
+func (sm SourceMap) Lookup(offset int) (Position, bool) {
+	var found Position
+	ok := false
+
+	for _, pos := range sm {
+		if pos.Offset > offset {
+			break
+		}
+		found = pos
+		ok = true
+	}
+
+	return found, ok
+}
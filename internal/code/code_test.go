@@ -0,0 +1,92 @@
+package code
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := map[string]struct {
+		op       Opcode
+		operands []int
+		want     []byte
+	}{
+		"two byte operand": {
+			op:       OpConstant,
+			operands: []int{65534},
+			want:     []byte{byte(OpConstant), 255, 254},
+		},
+		"one byte operand": {
+			op:       OpGetLocal,
+			operands: []int{255},
+			want:     []byte{byte(OpGetLocal), 255},
+		},
+		"no operands": {
+			op:       OpAdd,
+			operands: []int{},
+			want:     []byte{byte(OpAdd)},
+		},
+		"mixed operands": {
+			op:       OpClosure,
+			operands: []int{65534, 255},
+			want:     []byte{byte(OpClosure), 255, 254, 255},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Make(test.op, test.operands...)
+			if string(got) != string(test.want) {
+				t.Fatalf("got=%v, want=%v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+	}
+
+	want := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if got := concatted.String(); got != want {
+		t.Fatalf("got=%q, want=%q", got, want)
+	}
+}
+
+func TestSourceMapLookup(t *testing.T) {
+	sourceMap := SourceMap{
+		{Offset: 0, Line: 1, Col: 1},
+		{Offset: 4, Line: 2, Col: 5},
+	}
+
+	tests := map[string]struct {
+		offset int
+		want   Position
+	}{
+		"start of instruction": {offset: 4, want: Position{Offset: 4, Line: 2, Col: 5}},
+		"inside instruction":   {offset: 2, want: Position{Offset: 0, Line: 1, Col: 1}},
+		"past last position":   {offset: 9, want: Position{Offset: 4, Line: 2, Col: 5}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := sourceMap.Lookup(test.offset)
+			if !ok || got != test.want {
+				t.Fatalf("got=%+v, want=%+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,792 @@
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/code"
+	"github.com/avearmin/simple/internal/object"
+	"github.com/avearmin/simple/internal/token"
+)
+
+// Bytecode is a compiled program. Main holds the top level statements, and
+// returns the value of the last one.
+type Bytecode struct {
+	Main        *object.CompiledFunction
+	Constants   []object.Object
+	GlobalNames []string
+}
+
+// Disassemble prints the instructions of main, followed by the constant pool
+// and the instructions of every function in it.
+func (b *Bytecode) Disassemble() string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("%s:\n%s", b.Main.Inspect(), b.Main.Instructions))
+
+	for i, constant := range b.Constants {
+		builder.WriteString(fmt.Sprintf("\nconstant %d %s %s", i, constant.Type(), constant.Inspect()))
+
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			builder.WriteString(fmt.Sprintf(" locals=%d\n%s", fn.NumLocals, fn.Instructions))
+		}
+	}
+
+	return builder.String()
+}
+
+// CompilationScope holds the instructions of the function being compiled.
+type CompilationScope struct {
+	instructions code.Instructions
+	sourceMap    code.SourceMap
+	loops        []loop
+}
+
+// loop holds the positions of the jumps emitted for the break and continue
+// statements of a loop body, which are patched once the loop is compiled.
+type loop struct {
+	breakJumps    []int
+	continueJumps []int
+}
+
+type Compiler struct {
+	constants       []object.Object
+	intConstants    map[int64]int
+	stringConstants map[string]int
+
+	symbolTable *SymbolTable
+	globalNames []string
+
+	scopes     []CompilationScope
+	scopeIndex int
+}
+
+func New() *Compiler {
+	symbolTable := NewSymbolTable()
+	for i, def := range object.Builtins {
+		symbolTable.DefineBuiltin(i, def.Name)
+	}
+
+	return &Compiler{
+		constants:       []object.Object{},
+		intConstants:    map[int64]int{},
+		stringConstants: map[string]int{},
+		symbolTable:     symbolTable,
+		scopes:          []CompilationScope{{}},
+	}
+}
+
+func (c *Compiler) Compile(program *ast.Program) (*Bytecode, error) {
+	for i, stmt := range program.Statements {
+		if i == len(program.Statements)-1 {
+			if err := c.compileLastStatement(stmt); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		if err := c.compileStatement(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(program.Statements) == 0 {
+		c.emit(code.OpNil)
+	}
+	c.emit(code.OpReturnValue)
+
+	main := &object.CompiledFunction{
+		Name:         "main",
+		Instructions: c.currentInstructions(),
+		NumLocals:    c.symbolTable.NumLocals(),
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+	}
+
+	return &Bytecode{Main: main, Constants: c.constants, GlobalNames: c.globalNames}, nil
+}
+
+// compileLastStatement compiles the last top level statement so that it
+// leaves its value on the stack, as the evaluator returns it from Eval.
+func (c *Compiler) compileLastStatement(stmt ast.Statement) error {
+	switch stmt := stmt.(type) {
+	case ast.AssignStatement:
+		if err := c.compileAssignStatement(stmt); err != nil {
+			return err
+		}
+		return c.loadIdent(stmt.Name.Token)
+	case ast.ReassignStatement:
+		if err := c.compileReassignStatement(stmt); err != nil {
+			return err
+		}
+		return c.loadIdent(stmt.Name.Token)
+	case ast.FunctionAssignStatement:
+		if err := c.compileFunctionAssignStatement(stmt); err != nil {
+			return err
+		}
+		return c.loadIdent(stmt.Name.Token)
+	case ast.FnCall:
+		return c.compileFnCall(stmt)
+	default:
+		if err := c.compileStatement(stmt); err != nil {
+			return err
+		}
+		c.emit(code.OpNil)
+		return nil
+	}
+}
+
+func (c *Compiler) compileStatement(stmt ast.Statement) error {
+	switch stmt := stmt.(type) {
+	case ast.AssignStatement:
+		return c.compileAssignStatement(stmt)
+	case ast.ReassignStatement:
+		return c.compileReassignStatement(stmt)
+	case ast.ConditionalStatement:
+		return c.compileConditionalStatement(stmt)
+	case ast.FunctionAssignStatement:
+		return c.compileFunctionAssignStatement(stmt)
+	case ast.ReturnStatement:
+		if err := c.compileExpression(stmt.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+		return nil
+	case ast.ForLoopStatement:
+		return c.compileForLoopStatement(stmt)
+	case ast.ForEachStatement:
+		return c.compileForEachStatement(stmt)
+	case ast.WhileStatement:
+		return c.compileWhileStatement(stmt)
+	case ast.BreakStatement:
+		innermost, err := c.innermostLoop(stmt.Token)
+		if err != nil {
+			return err
+		}
+		innermost.breakJumps = append(innermost.breakJumps, c.emit(code.OpJump, 9999))
+		return nil
+	case ast.ContinueStatement:
+		innermost, err := c.innermostLoop(stmt.Token)
+		if err != nil {
+			return err
+		}
+		innermost.continueJumps = append(innermost.continueJumps, c.emit(code.OpJump, 9999))
+		return nil
+	case ast.FnCall:
+		if err := c.compileFnCall(stmt); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+		return nil
+	default:
+		tok := ast.TokenOf(stmt)
+		return fmt.Errorf("cannot compile statement '%s' on line %d col %d", stmt.TokenType(), tok.Line, tok.Col)
+	}
+}
+
+func (c *Compiler) compileAssignStatement(stmt ast.AssignStatement) error {
+	if err := c.compileExpression(stmt.Value); err != nil {
+		return err
+	}
+
+	symbol, isNew := c.define(stmt.Name.Value)
+	if symbol.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, symbol.Index)
+	} else if isNew {
+		c.emit(code.OpDefineLocal, symbol.Index)
+	} else {
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileReassignStatement(stmt ast.ReassignStatement) error {
+	if err := c.compileExpression(stmt.Value); err != nil {
+		return err
+	}
+
+	return c.storeIdent(stmt.Name.Token)
+}
+
+func (c *Compiler) compileConditionalStatement(stmt ast.ConditionalStatement) error {
+	endJumps := []int{}
+
+	branches := []ast.ElifBlock{{Token: stmt.Token, Condition: stmt.IfCondition, Statements: stmt.IfStatements}}
+	branches = append(branches, stmt.ElifBlocks...)
+
+	for _, branch := range branches {
+		if err := c.compileExpression(branch.Condition); err != nil {
+			return err
+		}
+		jumpNotTruePos := c.emitAt(ast.TokenOf(branch.Condition), code.OpJumpNotTrue, 9999)
+
+		if err := c.compileBlock(branch.Statements); err != nil {
+			return err
+		}
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+
+		c.changeOperand(jumpNotTruePos, len(c.currentInstructions()))
+	}
+
+	if err := c.compileBlock(stmt.ElseBlock.Statements); err != nil {
+		return err
+	}
+
+	for _, pos := range endJumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// compileForLoopStatement gives the initializer, condition and update a scope
+// of their own, and the body a scope nested inside it, as the evaluator does.
+func (c *Compiler) compileForLoopStatement(stmt ast.ForLoopStatement) error {
+	c.enterBlock()
+	defer c.leaveBlock()
+
+	if stmt.Initalizer != nil {
+		if err := c.compileStatement(stmt.Initalizer); err != nil {
+			return err
+		}
+	}
+
+	conditionPos := len(c.currentInstructions())
+	jumpNotTruePos := -1
+	if stmt.Condition != nil {
+		if err := c.compileExpression(stmt.Condition); err != nil {
+			return err
+		}
+		jumpNotTruePos = c.emitAt(ast.TokenOf(stmt.Condition), code.OpJumpNotTrue, 9999)
+	}
+
+	c.enterLoop()
+	if err := c.compileBlock(stmt.Statements); err != nil {
+		return err
+	}
+
+	updatePos := len(c.currentInstructions())
+	if stmt.Update != nil {
+		if err := c.compileStatement(stmt.Update); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpJump, conditionPos)
+
+	if jumpNotTruePos >= 0 {
+		c.changeOperand(jumpNotTruePos, len(c.currentInstructions()))
+	}
+	c.leaveLoop(updatePos)
+
+	return nil
+}
+
+// compileForEachStatement keeps an iterator on the stack for the length of
+// the loop. Each iteration defines the names in a fresh scope, and the body
+// in a scope nested inside it, as the evaluator does.
+func (c *Compiler) compileForEachStatement(stmt ast.ForEachStatement) error {
+	if err := c.compileExpression(stmt.Iterable); err != nil {
+		return err
+	}
+	c.emitAt(ast.TokenOf(stmt.Iterable), code.OpIter, len(stmt.Names))
+
+	nextPos := c.emit(code.OpIterNext, 9999)
+
+	c.enterBlock()
+	for i := len(stmt.Names) - 1; i >= 0; i-- {
+		symbol, isNew := c.define(stmt.Names[i].Value)
+		if isNew {
+			c.emit(code.OpDefineLocal, symbol.Index)
+		} else {
+			c.emit(code.OpSetLocal, symbol.Index)
+		}
+	}
+
+	c.enterLoop()
+	if err := c.compileBlock(stmt.Statements); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, nextPos)
+	c.leaveBlock()
+
+	c.changeOperand(nextPos, len(c.currentInstructions()))
+	c.leaveLoop(nextPos)
+	c.emit(code.OpPop)
+
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(stmt ast.WhileStatement) error {
+	conditionPos := len(c.currentInstructions())
+	if err := c.compileExpression(stmt.Condition); err != nil {
+		return err
+	}
+	jumpNotTruePos := c.emitAt(ast.TokenOf(stmt.Condition), code.OpJumpNotTrue, 9999)
+
+	c.enterLoop()
+	if err := c.compileBlock(stmt.Statements); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, conditionPos)
+
+	c.changeOperand(jumpNotTruePos, len(c.currentInstructions()))
+	c.leaveLoop(conditionPos)
+
+	return nil
+}
+
+func (c *Compiler) compileBlock(stmts []ast.Statement) error {
+	c.enterBlock()
+	defer c.leaveBlock()
+
+	c.declareLocals(stmts)
+	for _, stmt := range stmts {
+		if err := c.compileStatement(stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compileFunctionAssignStatement binds the function's name before compiling
+// its body, so the body can refer to the function recursively.
+func (c *Compiler) compileFunctionAssignStatement(stmt ast.FunctionAssignStatement) error {
+	symbol, isNew := c.define(stmt.Name.Value)
+	if symbol.Scope == LocalScope && isNew {
+		c.emit(code.OpNil)
+		c.emit(code.OpDefineLocal, symbol.Index)
+	}
+
+	if err := c.compileFunction(stmt.Name.Value, stmt.Params, stmt.Statements); err != nil {
+		return err
+	}
+
+	if symbol.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, symbol.Index)
+	} else {
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+
+	return nil
+}
+
+// compileFunction compiles a function body in a new scope, and emits the
+// instructions that create a closure over it.
+func (c *Compiler) compileFunction(name string, params []ast.Atom, stmts []ast.Statement) error {
+	c.enterScope()
+
+	paramNames := make([]string, 0, len(params))
+	for _, param := range params {
+		if _, isNew := c.symbolTable.Define(param.Value); !isNew {
+			return fmt.Errorf("duplicate parameter '%s' on line %d col %d", param.Value, param.Token.Line, param.Token.Col)
+		}
+		paramNames = append(paramNames, param.Value)
+	}
+
+	c.declareLocals(stmts)
+	for _, stmt := range stmts {
+		if err := c.compileStatement(stmt); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpReturn)
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumLocals()
+	instructions, sourceMap := c.leaveScope()
+
+	for _, symbol := range freeSymbols {
+		if symbol.Scope == FreeScope {
+			c.emit(code.OpGetFreeCell, symbol.Index)
+		} else {
+			c.emit(code.OpGetLocalCell, symbol.Index)
+		}
+	}
+
+	fn := &object.CompiledFunction{
+		Name:         name,
+		Parameters:   paramNames,
+		Instructions: instructions,
+		NumLocals:    numLocals,
+		SourceMap:    sourceMap,
+	}
+	c.emit(code.OpClosure, c.addConstant(fn), len(freeSymbols))
+
+	return nil
+}
+
+func (c *Compiler) compileFnCall(call ast.FnCall) error {
+	if err := c.compileExpression(call.Callee); err != nil {
+		return err
+	}
+
+	for _, arg := range call.Arguments {
+		if err := c.compileExpression(arg); err != nil {
+			return err
+		}
+	}
+
+	c.emitAt(call.Token, code.OpCall, len(call.Arguments))
+
+	return nil
+}
+
+func (c *Compiler) compileExpression(exp ast.Expression) error {
+	switch exp := exp.(type) {
+	case ast.Atom:
+		return c.compileAtom(exp)
+	case ast.BinaryExpression:
+		return c.compileBinaryExpression(exp)
+	case ast.FunctionLiteral:
+		return c.compileFunction(object.AnonymousFunction, exp.Params, exp.Statements)
+	case ast.ListLiteral:
+		return c.compileListLiteral(exp)
+	case ast.LogicalExpression:
+		return c.compileLogicalExpression(exp)
+	case ast.UnaryExpression:
+		return c.compileUnaryExpression(exp)
+	case ast.FnCall:
+		return c.compileFnCall(exp)
+	default:
+		tok := ast.TokenOf(exp)
+		return fmt.Errorf("cannot compile expression '%s' on line %d col %d", exp.TokenType(), tok.Line, tok.Col)
+	}
+}
+
+func (c *Compiler) compileAtom(atom ast.Atom) error {
+	switch atom.TokenType() {
+	case token.Int:
+		value, err := strconv.ParseInt(atom.Value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot use '%s' as an integer on line %d col %d", atom.Value, atom.Token.Line, atom.Token.Col)
+		}
+		c.emit(code.OpConstant, c.addIntConstant(value))
+	case token.Float:
+		value, err := strconv.ParseFloat(atom.Value, 64)
+		if err != nil {
+			return fmt.Errorf("cannot use '%s' as a float on line %d col %d", atom.Value, atom.Token.Line, atom.Token.Col)
+		}
+		c.emit(code.OpConstant, c.addConstant(object.Float{Value: value}))
+	case token.Bool:
+		if atom.Value == "true" {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case token.String:
+		c.emit(code.OpConstant, c.addStringConstant(atom.Value))
+	case token.Nil:
+		c.emit(code.OpNil)
+	case token.Ident:
+		return c.loadIdent(atom.Token)
+	default:
+		return fmt.Errorf("cannot compile atom '%s' on line %d col %d", atom.TokenType(), atom.Token.Line, atom.Token.Col)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileListLiteral(list ast.ListLiteral) error {
+	for _, exp := range list.Elements {
+		if err := c.compileExpression(exp); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpList, len(list.Elements))
+
+	return nil
+}
+
+// compileLogicalExpression jumps to the end as soon as an operand decides the
+// result, leaving that operand on the stack. If none does, the result is
+// true for && and false for ||.
+func (c *Compiler) compileLogicalExpression(exp ast.LogicalExpression) error {
+	jumpOp, result := code.OpJumpFalseOrPop, code.OpTrue
+	if exp.TokenType() == token.Or {
+		jumpOp, result = code.OpJumpTrueOrPop, code.OpFalse
+	}
+
+	endJumps := []int{}
+	for _, operand := range exp.Operands {
+		if err := c.compileExpression(operand); err != nil {
+			return err
+		}
+		endJumps = append(endJumps, c.emitAt(exp.Token, jumpOp, 9999))
+	}
+	c.emit(result)
+
+	for _, pos := range endJumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileUnaryExpression(exp ast.UnaryExpression) error {
+	if err := c.compileExpression(exp.Operand); err != nil {
+		return err
+	}
+	c.emitAt(exp.Token, code.OpNot)
+
+	return nil
+}
+
+var binaryOpcodes = map[token.Type]code.Opcode{
+	token.Add:                 code.OpAdd,
+	token.Subtract:            code.OpSubtract,
+	token.Multiply:            code.OpMultiply,
+	token.Divide:              code.OpDivide,
+	token.Modulo:              code.OpModulo,
+	token.Equals:              code.OpEquals,
+	token.NotEquals:           code.OpNotEquals,
+	token.LessThan:            code.OpLessThan,
+	token.GreaterThan:         code.OpGreaterThan,
+	token.LessThanOrEquals:    code.OpLessThanOrEquals,
+	token.GreaterThanOrEquals: code.OpGreaterThanOrEquals,
+}
+
+func (c *Compiler) compileBinaryExpression(exp ast.BinaryExpression) error {
+	op, ok := binaryOpcodes[exp.TokenType()]
+	if !ok {
+		return fmt.Errorf("unknown operator '%s' on line %d col %d", exp.TokenType(), exp.Token.Line, exp.Token.Col)
+	}
+
+	if err := c.compileExpression(exp.First); err != nil {
+		return err
+	}
+	if err := c.compileExpression(exp.Second); err != nil {
+		return err
+	}
+
+	c.emitAt(exp.Token, op)
+
+	return nil
+}
+
+// define binds name in the current scope, recording the name of any global
+// it creates for error messages.
+func (c *Compiler) define(name string) (Symbol, bool) {
+	symbol, isNew := c.symbolTable.Define(name)
+	if symbol.Scope == GlobalScope && isNew {
+		c.globalNames = append(c.globalNames, name)
+	}
+	return symbol, isNew
+}
+
+// declareLocals gives a local slot, before any of stmts is compiled, to each
+// name they assign or declare as a function that a function among them uses
+// ahead of its definition, such as two functions that call each other.
+func (c *Compiler) declareLocals(stmts []ast.Statement) {
+	defined := map[string]int{}
+	for i, stmt := range stmts {
+		if name, ok := definedName(stmt); ok {
+			if _, seen := defined[name]; !seen {
+				defined[name] = i
+			}
+		}
+	}
+
+	for i, stmt := range stmts {
+		_, isFunction := stmt.(ast.FunctionAssignStatement)
+		declare := func(node ast.Node) bool {
+			atom, ok := node.(ast.Atom)
+			if !ok || atom.TokenType() != token.Ident {
+				return true
+			}
+			// A function statement binds its own name before its body.
+			first, ok := defined[atom.Value]
+			if !ok || first < i || (first == i && isFunction) {
+				return true
+			}
+
+			if symbol, isNew := c.symbolTable.Declare(atom.Value); isNew {
+				c.emit(code.OpNil)
+				c.emit(code.OpDefineLocal, symbol.Index)
+			}
+			return true
+		}
+
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			switch node.(type) {
+			case ast.FunctionAssignStatement, ast.FunctionLiteral:
+				ast.Inspect(node, declare)
+				return false
+			}
+			return true
+		})
+	}
+}
+
+// definedName returns the name stmt binds in the scope it appears in.
+func definedName(stmt ast.Statement) (string, bool) {
+	switch stmt := stmt.(type) {
+	case ast.AssignStatement:
+		return stmt.Name.Value, true
+	case ast.FunctionAssignStatement:
+		return stmt.Name.Value, true
+	default:
+		return "", false
+	}
+}
+
+// resolve finds the binding of the identifier in tok. Names that are not
+// bound yet are treated as globals, as the evaluator looks names up when
+// they are used rather than when they are defined.
+func (c *Compiler) resolve(name string) Symbol {
+	if symbol, ok := c.symbolTable.Resolve(name); ok {
+		return symbol
+	}
+
+	symbol := c.symbolTable.DefineGlobal(name)
+	if symbol.Index == len(c.globalNames) {
+		c.globalNames = append(c.globalNames, name)
+	}
+	return symbol
+}
+
+func (c *Compiler) loadIdent(tok token.Token) error {
+	symbol := c.resolve(tok.Literal)
+
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emitAt(tok, code.OpGetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, symbol.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, symbol.Index)
+	}
+
+	return nil
+}
+
+func (c *Compiler) storeIdent(tok token.Token) error {
+	symbol := c.resolve(tok.Literal)
+
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emitAt(tok, code.OpReassignGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, symbol.Index)
+	case BuiltinScope:
+		return fmt.Errorf("cannot reassign builtin '%s' on line %d col %d", tok.Literal, tok.Line, tok.Col)
+	}
+
+	return nil
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) addIntConstant(value int64) int {
+	if index, ok := c.intConstants[value]; ok {
+		return index
+	}
+
+	index := c.addConstant(object.Integer{Value: value})
+	c.intConstants[value] = index
+	return index
+}
+
+func (c *Compiler) addStringConstant(value string) int {
+	if index, ok := c.stringConstants[value]; ok {
+		return index
+	}
+
+	index := c.addConstant(object.String{Value: value})
+	c.stringConstants[value] = index
+	return index
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+
+	pos := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+
+	return pos
+}
+
+// emitAt emits an instruction that can fail at runtime, and records the
+// position of tok so the failure can be reported there.
+func (c *Compiler) emitAt(tok token.Token, op code.Opcode, operands ...int) int {
+	pos := c.emit(op, operands...)
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.sourceMap = append(scope.sourceMap, code.Position{Offset: pos, Line: tok.Line, Col: tok.Col})
+
+	return pos
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	copy(c.scopes[c.scopeIndex].instructions[opPos:], newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedFunctionTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, code.SourceMap) {
+	scope := c.scopes[c.scopeIndex]
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope.instructions, scope.sourceMap
+}
+
+func (c *Compiler) enterLoop() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, loop{})
+}
+
+// leaveLoop patches the break jumps of the innermost loop to the current
+// position, and its continue jumps to continuePos.
+func (c *Compiler) leaveLoop(continuePos int) {
+	scope := &c.scopes[c.scopeIndex]
+	innermost := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range innermost.breakJumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	for _, pos := range innermost.continueJumps {
+		c.changeOperand(pos, continuePos)
+	}
+}
+
+// innermostLoop returns the loop the break or continue at tok belongs to.
+func (c *Compiler) innermostLoop(tok token.Token) (*loop, error) {
+	scope := &c.scopes[c.scopeIndex]
+	if len(scope.loops) == 0 {
+		return nil, fmt.Errorf("'%s' on line %d col %d is not inside a loop", tok.Literal, tok.Line, tok.Col)
+	}
+	return &scope.loops[len(scope.loops)-1], nil
+}
+
+func (c *Compiler) enterBlock() {
+	c.symbolTable = NewEnclosedBlockTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveBlock() {
+	c.symbolTable.leave()
+	c.symbolTable = c.symbolTable.Outer
+}
@@ -0,0 +1,261 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/avearmin/simple/internal/code"
+	"github.com/avearmin/simple/internal/lexer"
+	"github.com/avearmin/simple/internal/object"
+	"github.com/avearmin/simple/internal/parser"
+)
+
+func TestCompile(t *testing.T) {
+	tests := map[string]struct {
+		input         string
+		wantConstants []object.Object
+		wantMain      []code.Instructions
+	}{
+		"assign": {
+			input:         "(:= foo (+ 1 2))",
+			wantConstants: []object.Object{object.Integer{Value: 1}, object.Integer{Value: 2}},
+			wantMain: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		"reassign": {
+			input:         "(:= foo 1)\n(= foo 1)",
+			wantConstants: []object.Object{object.Integer{Value: 1}},
+			wantMain: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpReassignGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		"logical and unary": {
+			input:         "(:= foo (&& true (! false)))",
+			wantConstants: []object.Object{},
+			wantMain: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpFalseOrPop, 10),
+				code.Make(code.OpFalse),
+				code.Make(code.OpNot),
+				code.Make(code.OpJumpFalseOrPop, 10),
+				code.Make(code.OpTrue),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		"list": {
+			input:         "(:= foo (list 1 (+ 1 1)))",
+			wantConstants: []object.Object{object.Integer{Value: 1}},
+			wantMain: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpAdd),
+				code.Make(code.OpList, 2),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		"if else": {
+			input:         "(if true (:= foo 1) else (:= foo 2))",
+			wantConstants: []object.Object{object.Integer{Value: 1}, object.Integer{Value: 2}},
+			wantMain: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTrue, 12),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpDefineLocal, 0),
+				code.Make(code.OpJump, 17),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpDefineLocal, 0),
+				code.Make(code.OpNil),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		"for loop": {
+			input:         "(for (:= i 0) (< i 2) (= i (+ i 1)) (print i))",
+			wantConstants: []object.Object{object.Integer{Value: 0}, object.Integer{Value: 2}, object.Integer{Value: 1}},
+			wantMain: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpDefineLocal, 0),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpJumpNotTrue, 32),
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpJump, 5),
+				code.Make(code.OpNil),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		"for each": {
+			input:         "(for x in (list) (print x))",
+			wantConstants: []object.Object{},
+			wantMain: []code.Instructions{
+				code.Make(code.OpList, 0),
+				code.Make(code.OpIter, 1),
+				code.Make(code.OpIterNext, 20),
+				code.Make(code.OpDefineLocal, 0),
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 5),
+				code.Make(code.OpPop),
+				code.Make(code.OpNil),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		"while with break and continue": {
+			input:         "(while true (if false (continue)) (break))",
+			wantConstants: []object.Object{},
+			wantMain: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTrue, 20),
+				code.Make(code.OpFalse),
+				code.Make(code.OpJumpNotTrue, 14),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpJump, 14),
+				code.Make(code.OpJump, 20),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpNil),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bytecode := compile(t, test.input)
+
+			want := concatInstructions(test.wantMain)
+			if bytecode.Main.Instructions.String() != want.String() {
+				t.Fatalf("got=\n%s\nwant=\n%s", bytecode.Main.Instructions, want)
+			}
+
+			if len(bytecode.Constants) != len(test.wantConstants) {
+				t.Fatalf("len(want)=%d, len(got)=%d", len(test.wantConstants), len(bytecode.Constants))
+			}
+			for i := range test.wantConstants {
+				if bytecode.Constants[i] != test.wantConstants[i] {
+					t.Fatalf("%d: got=%s, want=%s", i, bytecode.Constants[i].Inspect(), test.wantConstants[i].Inspect())
+				}
+			}
+		})
+	}
+}
+
+func TestCompileClosures(t *testing.T) {
+	input := `(fn outer a
+    (fn inner b
+        (= a b)
+        (return a))
+    (return inner))`
+
+	bytecode := compile(t, input)
+
+	wantInner := concatInstructions([]code.Instructions{
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpSetFree, 0),
+		code.Make(code.OpGetFree, 0),
+		code.Make(code.OpReturnValue),
+		code.Make(code.OpReturn),
+	})
+	wantOuter := concatInstructions([]code.Instructions{
+		code.Make(code.OpNil),
+		code.Make(code.OpDefineLocal, 1),
+		code.Make(code.OpGetLocalCell, 0),
+		code.Make(code.OpClosure, 0, 1),
+		code.Make(code.OpSetLocal, 1),
+		code.Make(code.OpGetLocal, 1),
+		code.Make(code.OpReturnValue),
+		code.Make(code.OpReturn),
+	})
+
+	inner, ok := bytecode.Constants[0].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("expected constant 0 to be *object.CompiledFunction, but got %T", bytecode.Constants[0])
+	}
+	if inner.Instructions.String() != wantInner.String() {
+		t.Fatalf("got=\n%s\nwant=\n%s", inner.Instructions, wantInner)
+	}
+
+	outer, ok := bytecode.Constants[1].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("expected constant 1 to be *object.CompiledFunction, but got %T", bytecode.Constants[1])
+	}
+	if outer.Instructions.String() != wantOuter.String() {
+		t.Fatalf("got=\n%s\nwant=\n%s", outer.Instructions, wantOuter)
+	}
+	if outer.NumLocals != 2 {
+		t.Fatalf("expected outer to have 2 locals, but got %d", outer.NumLocals)
+	}
+}
+
+func compile(t *testing.T, input string) *Bytecode {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("Parsing failed with error: %s", err.Error())
+	}
+
+	bytecode, err := New().Compile(program)
+	if err != nil {
+		t.Fatalf("Compiling failed with error: %s", err.Error())
+	}
+
+	return bytecode
+}
+
+func concatInstructions(instructions []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestDisassemble(t *testing.T) {
+	bytecode := compile(t, "(fn id x (return x))\n(id 1)")
+
+	want := `fn main():
+0000 OpClosure 0 0
+0004 OpSetGlobal 0
+0007 OpGetGlobal 0
+0010 OpConstant 1
+0013 OpCall 1
+0015 OpReturnValue
+
+constant 0 COMPILED_FUNCTION fn id(x) locals=1
+0000 OpGetLocal 0
+0002 OpReturnValue
+0003 OpReturn
+
+constant 1 INTEGER 1`
+
+	if got := bytecode.Disassemble(); got != want {
+		t.Fatalf("got=\n%s\nwant=\n%s", got, want)
+	}
+}
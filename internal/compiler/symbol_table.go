@@ -0,0 +1,204 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	FreeScope    SymbolScope = "FREE"
+	BuiltinScope SymbolScope = "BUILTIN"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// locals counts the local slots of a single frame. Block scopes share the
+// locals of the function, or top level program, they appear in.
+type locals struct {
+	next int
+	max  int
+}
+
+// SymbolTable is a single scope of a program. The outermost table holds the
+// globals and builtins. Function bodies, conditional branches and loops each
+// get a table enclosed by the scope they appear in.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store      map[string]Symbol
+	pending    map[string]Symbol
+	locals     *locals
+	isFunction bool
+
+	numGlobals  int
+	FreeSymbols []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: map[string]Symbol{}, locals: &locals{}}
+}
+
+// NewEnclosedBlockTable creates a scope whose locals live in the same frame
+// as the locals of outer.
+func NewEnclosedBlockTable(outer *SymbolTable) *SymbolTable {
+	return &SymbolTable{Outer: outer, store: map[string]Symbol{}, locals: outer.locals}
+}
+
+// NewEnclosedFunctionTable creates the scope of a function body, whose
+// locals live in a frame of their own.
+func NewEnclosedFunctionTable(outer *SymbolTable) *SymbolTable {
+	return &SymbolTable{Outer: outer, store: map[string]Symbol{}, locals: &locals{}, isFunction: true}
+}
+
+// Define binds name in this scope. It reports false if name was already a
+// variable of this scope, in which case the existing symbol is returned.
+func (s *SymbolTable) Define(name string) (Symbol, bool) {
+	if symbol, ok := s.pending[name]; ok {
+		delete(s.pending, name)
+		s.store[name] = symbol
+		return symbol, false
+	}
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == LocalScope || symbol.Scope == GlobalScope) {
+		return symbol, false
+	}
+
+	var symbol Symbol
+	if s.Outer == nil {
+		symbol = Symbol{Name: name, Scope: GlobalScope, Index: s.numGlobals}
+		s.numGlobals++
+	} else {
+		symbol = Symbol{Name: name, Scope: LocalScope, Index: s.locals.next}
+		s.locals.next++
+		if s.locals.next > s.locals.max {
+			s.locals.max = s.locals.next
+		}
+	}
+
+	s.store[name] = symbol
+	return symbol, true
+}
+
+// Declare reserves a local slot for name before it is defined, so functions
+// in this scope can refer to it ahead of its definition. Until Define is
+// called with name, it is only visible from inside those functions, and
+// everything else still finds the binding name has in an enclosing scope.
+// It reports false if name already has a slot in this scope.
+func (s *SymbolTable) Declare(name string) (Symbol, bool) {
+	if symbol, ok := s.pending[name]; ok {
+		return symbol, false
+	}
+	if symbol, ok := s.store[name]; ok && symbol.Scope == LocalScope {
+		return symbol, false
+	}
+
+	symbol := Symbol{Name: name, Scope: LocalScope, Index: s.locals.next}
+	s.locals.next++
+	if s.locals.next > s.locals.max {
+		s.locals.max = s.locals.next
+	}
+
+	if s.pending == nil {
+		s.pending = map[string]Symbol{}
+	}
+	s.pending[name] = symbol
+	return symbol, true
+}
+
+// DefineGlobal binds name in the outermost scope, without shadowing any
+// binding it already has there.
+func (s *SymbolTable) DefineGlobal(name string) Symbol {
+	global := s
+	for global.Outer != nil {
+		global = global.Outer
+	}
+
+	if symbol, ok := global.store[name]; ok && symbol.Scope == GlobalScope {
+		return symbol
+	}
+
+	symbol, _ := global.Define(name)
+	return symbol
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Scope: BuiltinScope, Index: index}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// Resolve finds the nearest binding of name. Locals of an enclosing function
+// are turned into free variables of every function in between.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	return s.resolve(name, false)
+}
+
+// resolve finds the nearest binding of name, including the names declared
+// ahead of their definition if the lookup started inside a nested function.
+func (s *SymbolTable) resolve(name string, inFunction bool) (Symbol, bool) {
+	if symbol, ok := s.pending[name]; ok && inFunction {
+		return symbol, true
+	}
+	if symbol, ok := s.store[name]; ok {
+		return symbol, true
+	}
+
+	if s.Outer == nil {
+		return Symbol{}, false
+	}
+
+	symbol, ok := s.Outer.resolve(name, inFunction || s.isFunction)
+	if !ok || symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+		return symbol, ok
+	}
+
+	if s.isFunction {
+		return s.defineFree(symbol), true
+	}
+
+	return symbol, true
+}
+
+// NumLocals is the number of local slots needed by the frame this scope's
+// locals live in.
+func (s *SymbolTable) NumLocals() int {
+	return s.locals.max
+}
+
+// NumGlobals is the number of global slots defined so far.
+func (s *SymbolTable) NumGlobals() int {
+	global := s
+	for global.Outer != nil {
+		global = global.Outer
+	}
+	return global.numGlobals
+}
+
+// leave releases the local slots of a block scope once its statements have
+// been compiled, so sibling blocks can reuse them.
+func (s *SymbolTable) leave() {
+	if s.isFunction || s.Outer == nil {
+		return
+	}
+	s.locals.next -= s.definedLocals()
+}
+
+func (s *SymbolTable) definedLocals() int {
+	count := len(s.pending)
+	for _, symbol := range s.store {
+		if symbol.Scope == LocalScope {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,122 @@
+// Package diagnostics describes problems found in a program's source, and
+// renders them with the part of the source they are about.
+package diagnostics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/avearmin/simple/internal/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Code identifies the kind of a diagnostic. Codes starting with L are found
+// by the lexer, those starting with P by the parser, those starting with R
+// by the resolver, and those starting with T by the type checker.
+type Code string
+
+const (
+	InvalidLiteral      Code = "L001"
+	UnterminatedString  Code = "L002"
+	InvalidEscape       Code = "L003"
+	UnterminatedComment Code = "L004"
+
+	UnexpectedToken Code = "P001"
+	OutsideLoop     Code = "P002"
+	TooFewOperands  Code = "P003"
+
+	UndefinedName      Code = "R001"
+	UndeclaredReassign Code = "R002"
+	DuplicateParameter Code = "R003"
+
+	OperandMismatch    Code = "T001"
+	NonBoolCondition   Code = "T002"
+	InconsistentReturn Code = "T003"
+	TypeMismatch       Code = "T004"
+	InfiniteType       Code = "T005"
+)
+
+// Position is a place in the source. Lines count from 1 and columns from 0,
+// as they do in tokens.
+type Position struct {
+	Line int
+	Col  int
+}
+
+// Span is the part of the source from Start up to, but not including, End.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// TokenSpan returns the span of tok, assuming it does not cross lines.
+func TokenSpan(tok token.Token) Span {
+	start := Position{Line: tok.Line, Col: tok.Col}
+	return Span{Start: start, End: Position{Line: tok.Line, Col: tok.Col + len(tok.Literal)}}
+}
+
+// Fix is a suggested change to the source that would resolve a diagnostic:
+// the text in Span replaced with Replacement.
+type Fix struct {
+	Message     string
+	Span        Span
+	Replacement string
+}
+
+// Diagnostic is a problem found in the source. When it is about a token
+// that does not belong where it is, Found is the type of that token and
+// Expected the types that would have been accepted in its place.
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Span     Span
+	Message  string
+	Notes    []string
+	Fix      *Fix
+
+	Expected []token.Type
+	Found    token.Type
+}
+
+// New creates an error diagnostic for the given span.
+func New(code Code, span Span, format string, a ...any) *Diagnostic {
+	return &Diagnostic{Severity: Error, Code: code, Span: span, Message: fmt.Sprintf(format, a...)}
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s on line %d col %d", d.Message, d.Span.Start.Line, d.Span.Start.Col)
+}
+
+// Note adds a note to d and returns d.
+func (d *Diagnostic) Note(format string, a ...any) *Diagnostic {
+	d.Notes = append(d.Notes, fmt.Sprintf(format, a...))
+	return d
+}
+
+// List is the diagnostics of a program, in the order they were found.
+type List []*Diagnostic
+
+func (l List) Error() string {
+	messages := make([]string, len(l))
+	for i, d := range l {
+		messages[i] = d.Error()
+	}
+	return strings.Join(messages, "\n")
+}
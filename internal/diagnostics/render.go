@@ -0,0 +1,77 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Render writes d to w in this form, where the snippet is the line of source
+// the diagnostic starts on, with carets under the part of it that is wrong:
+//
+//	error[P001]: expected ')', but got 'INT'
+//	 --> main.smp:1:12
+//	  |
+//	1 | (:= x (! a b))
+//	  |            ^
+//	  = note: ...
+//	  = help: ...
+func Render(w io.Writer, filename, source string, d *Diagnostic) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+
+	start := d.Span.Start
+	gutter := strings.Repeat(" ", len(strconv.Itoa(start.Line)))
+	fmt.Fprintf(&b, "%s--> %s:%d:%d\n", gutter, filename, start.Line, start.Col)
+
+	if line, ok := sourceLine(source, start.Line); ok {
+		fmt.Fprintf(&b, "%s |\n", gutter)
+		fmt.Fprintf(&b, "%d | %s\n", start.Line, line)
+		fmt.Fprintf(&b, "%s | %s%s\n", gutter, caretIndent(line, start.Col), strings.Repeat("^", caretWidth(line, d.Span)))
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(&b, "%s = note: %s\n", gutter, note)
+	}
+	if d.Fix != nil {
+		fmt.Fprintf(&b, "%s = help: %s\n", gutter, d.Fix.Message)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sourceLine returns the text of the given line of source, counting from 1.
+func sourceLine(source string, line int) (string, bool) {
+	lines := strings.Split(source, "\n")
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[line-1], "\r"), true
+}
+
+// caretIndent returns the whitespace that lines a caret up under col of
+// line. Tabs are kept, so the caret lines up whatever their width.
+func caretIndent(line string, col int) string {
+	var b strings.Builder
+	for i := 0; i < col; i++ {
+		if i < len(line) && line[i] == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// caretWidth returns the number of carets to put under span, which are cut
+// off at the end of line when it runs past it. There is always at least one.
+func caretWidth(line string, span Span) int {
+	end := len(line)
+	if span.End.Line == span.Start.Line && span.End.Col < end {
+		end = span.End.Col
+	}
+	return max(end-span.Start.Col, 1)
+}
@@ -0,0 +1,84 @@
+package diagnostics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := map[string]struct {
+		source string
+		input  *Diagnostic
+		want   string
+	}{
+		"caret under a token": {
+			source: "(:= x 1)\n(:= 1 y)",
+			input:  New(UnexpectedToken, span(2, 4, 5), "expected 'IDENT', but got 'INT'"),
+			want: "error[P001]: expected 'IDENT', but got 'INT'\n" +
+				" --> main.smp:2:4\n" +
+				"  |\n" +
+				"2 | (:= 1 y)\n" +
+				"  |     ^\n",
+		},
+		"carets under a span": {
+			source: `(print "a\qb")`,
+			input:  New(InvalidEscape, span(1, 9, 11), `invalid escape sequence '\q'`),
+			want: "error[L003]: invalid escape sequence '\\q'\n" +
+				" --> main.smp:1:9\n" +
+				"  |\n" +
+				"1 | (print \"a\\qb\")\n" +
+				"  |          ^^\n",
+		},
+		"span past the end of the line": {
+			source: "(print \"abc\n",
+			input:  New(UnterminatedString, Span{Start: Position{1, 7}, End: Position{2, 0}}, "string is not terminated"),
+			want: "error[L002]: string is not terminated\n" +
+				" --> main.smp:1:7\n" +
+				"  |\n" +
+				"1 | (print \"abc\n" +
+				"  |        ^^^^\n",
+		},
+		"tabs are kept": {
+			source: "\t(:= x)",
+			input:  New(UnexpectedToken, span(1, 6, 7), "expected 'DELIMITER', but got ')'"),
+			want: "error[P001]: expected 'DELIMITER', but got ')'\n" +
+				" --> main.smp:1:6\n" +
+				"  |\n" +
+				"1 | \t(:= x)\n" +
+				"  | \t     ^\n",
+		},
+		"notes and help": {
+			source: "(:= x 1",
+			input: func() *Diagnostic {
+				d := New(UnexpectedToken, span(1, 7, 7), "expected ')', but got 'EOF'").
+					Note("the input ended before every '(' was closed")
+				d.Fix = &Fix{Message: "insert ')'", Span: span(1, 7, 7), Replacement: ")"}
+				return d
+			}(),
+			want: "error[P001]: expected ')', but got 'EOF'\n" +
+				" --> main.smp:1:7\n" +
+				"  |\n" +
+				"1 | (:= x 1\n" +
+				"  |        ^\n" +
+				"  = note: the input ended before every '(' was closed\n" +
+				"  = help: insert ')'\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b strings.Builder
+			if err := Render(&b, "main.smp", test.source, test.input); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := b.String(); got != test.want {
+				t.Fatalf("got=\n%s\nwant=\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func span(line, start, end int) Span {
+	return Span{Start: Position{Line: line, Col: start}, End: Position{Line: line, Col: end}}
+}
@@ -0,0 +1,524 @@
+package evaluator
+
+import (
+	"strconv"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/object"
+	"github.com/avearmin/simple/internal/token"
+)
+
+var (
+	True  = object.Boolean{Value: true}
+	False = object.Boolean{Value: false}
+	Nil   = object.Nil{}
+)
+
+// Eval runs every statement of the program in env and returns the value of
+// the last statement executed, or the value of a top level return. Bindings
+// made by the program are left in env, so it can be reused across calls.
+func Eval(program *ast.Program, env *object.Environment) (object.Object, error) {
+	result, err := evalStatements(program.Statements, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if returnValue, ok := result.(object.ReturnValue); ok {
+		return returnValue.Value, nil
+	}
+
+	return result, nil
+}
+
+func evalStatements(stmts []ast.Statement, env *object.Environment) (object.Object, error) {
+	var result object.Object = Nil
+
+	for _, stmt := range stmts {
+		obj, err := evalStatement(stmt, env)
+		if err != nil {
+			return nil, err
+		}
+
+		if interrupts(obj) {
+			return obj, nil
+		}
+
+		result = obj
+	}
+
+	return result, nil
+}
+
+func evalStatement(stmt ast.Statement, env *object.Environment) (object.Object, error) {
+	switch stmt := stmt.(type) {
+	case ast.AssignStatement:
+		return evalAssignStatement(stmt, env)
+	case ast.ReassignStatement:
+		return evalReassignStatement(stmt, env)
+	case ast.ConditionalStatement:
+		return evalConditionalStatement(stmt, env)
+	case ast.FunctionAssignStatement:
+		fn := &object.Function{
+			Name:       stmt.Name.Value,
+			Parameters: stmt.Params,
+			Statements: stmt.Statements,
+			Env:        env,
+		}
+		return env.Set(stmt.Name.Value, fn), nil
+	case ast.ReturnStatement:
+		value, err := evalExpression(stmt.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		return object.ReturnValue{Value: value}, nil
+	case ast.ForLoopStatement:
+		return evalForLoopStatement(stmt, env)
+	case ast.ForEachStatement:
+		return evalForEachStatement(stmt, env)
+	case ast.WhileStatement:
+		return evalWhileStatement(stmt, env)
+	case ast.BreakStatement:
+		return loopSignal{Break: true}, nil
+	case ast.ContinueStatement:
+		return loopSignal{Break: false}, nil
+	case ast.FnCall:
+		return evalFnCall(stmt, env)
+	default:
+		tok := ast.TokenOf(stmt)
+		return nil, newError(object.UnsupportedError, tok, "cannot evaluate statement '%s'", stmt.TokenType())
+	}
+}
+
+func evalAssignStatement(stmt ast.AssignStatement, env *object.Environment) (object.Object, error) {
+	value, err := evalExpression(stmt.Value, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return env.Set(stmt.Name.Value, value), nil
+}
+
+func evalReassignStatement(stmt ast.ReassignStatement, env *object.Environment) (object.Object, error) {
+	value, err := evalExpression(stmt.Value, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if !env.Reassign(stmt.Name.Value, value) {
+		return nil, newError(object.ReassignBeforeAssignError, stmt.Name.Token,
+			"cannot reassign '%s' before it is assigned", stmt.Name.Value)
+	}
+
+	return value, nil
+}
+
+func evalConditionalStatement(stmt ast.ConditionalStatement, env *object.Environment) (object.Object, error) {
+	ok, err := evalCondition(stmt.IfCondition, env)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return evalBlock(stmt.IfStatements, env)
+	}
+
+	for _, elifBlock := range stmt.ElifBlocks {
+		ok, err := evalCondition(elifBlock.Condition, env)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return evalBlock(elifBlock.Statements, env)
+		}
+	}
+
+	return evalBlock(stmt.ElseBlock.Statements, env)
+}
+
+// evalForLoopStatement runs the initializer, condition and update in a scope
+// of their own, and each iteration of the body in a fresh scope nested inside
+// it.
+func evalForLoopStatement(stmt ast.ForLoopStatement, env *object.Environment) (object.Object, error) {
+	loopEnv := object.NewEnclosedEnvironment(env)
+
+	if stmt.Initalizer != nil {
+		result, err := evalStatement(stmt.Initalizer, loopEnv)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := result.(object.ReturnValue); ok {
+			return result, nil
+		}
+	}
+
+	for {
+		if stmt.Condition != nil {
+			ok, err := evalCondition(stmt.Condition, loopEnv)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+		}
+
+		result, err := evalBlock(stmt.Statements, loopEnv)
+		if err != nil {
+			return nil, err
+		}
+		if signal, ok := result.(loopSignal); ok && signal.Break {
+			break
+		}
+		if _, ok := result.(object.ReturnValue); ok {
+			return result, nil
+		}
+
+		if stmt.Update != nil {
+			result, err := evalStatement(stmt.Update, loopEnv)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := result.(object.ReturnValue); ok {
+				return result, nil
+			}
+		}
+	}
+
+	return Nil, nil
+}
+
+// evalForEachStatement binds the names to the values of each item in a fresh
+// scope, and runs the body in a scope nested inside it.
+func evalForEachStatement(stmt ast.ForEachStatement, env *object.Environment) (object.Object, error) {
+	iterable, err := evalExpression(stmt.Iterable, env)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, ok := object.NewIterator(iterable, len(stmt.Names))
+	if !ok {
+		return nil, newError(object.TypeMismatchError, ast.TokenOf(stmt.Iterable),
+			"cannot iterate over a value of type %s", iterable.Type())
+	}
+
+	for {
+		values, ok := iterator.Next()
+		if !ok {
+			break
+		}
+
+		itemEnv := object.NewEnclosedEnvironment(env)
+		for i, name := range stmt.Names {
+			itemEnv.Set(name.Value, values[i])
+		}
+
+		result, err := evalBlock(stmt.Statements, itemEnv)
+		if err != nil {
+			return nil, err
+		}
+		if signal, ok := result.(loopSignal); ok && signal.Break {
+			break
+		}
+		if _, ok := result.(object.ReturnValue); ok {
+			return result, nil
+		}
+	}
+
+	return Nil, nil
+}
+
+// evalWhileStatement runs the body in a fresh scope for as long as the
+// condition holds.
+func evalWhileStatement(stmt ast.WhileStatement, env *object.Environment) (object.Object, error) {
+	for {
+		ok, err := evalCondition(stmt.Condition, env)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+
+		result, err := evalBlock(stmt.Statements, env)
+		if err != nil {
+			return nil, err
+		}
+		if signal, ok := result.(loopSignal); ok && signal.Break {
+			break
+		}
+		if _, ok := result.(object.ReturnValue); ok {
+			return result, nil
+		}
+	}
+
+	return Nil, nil
+}
+
+// evalBlock evaluates the statements of a conditional branch or loop body in
+// a new scope nested inside env. A block only produces a value when it
+// returns, breaks or continues.
+func evalBlock(stmts []ast.Statement, env *object.Environment) (object.Object, error) {
+	result, err := evalStatements(stmts, object.NewEnclosedEnvironment(env))
+	if err != nil {
+		return nil, err
+	}
+
+	if interrupts(result) {
+		return result, nil
+	}
+
+	return Nil, nil
+}
+
+// loopSignal is the result of a break or continue. It ends every block it
+// passes through until it reaches the innermost loop, which the parser
+// guarantees exists.
+type loopSignal struct {
+	Break bool
+}
+
+func (ls loopSignal) Type() object.Type { return "LOOP_SIGNAL" }
+func (ls loopSignal) Inspect() string {
+	if ls.Break {
+		return "break"
+	}
+	return "continue"
+}
+
+// interrupts reports whether obj stops the statements after it from running.
+func interrupts(obj object.Object) bool {
+	switch obj.(type) {
+	case object.ReturnValue, loopSignal:
+		return true
+	default:
+		return false
+	}
+}
+
+func evalCondition(exp ast.Expression, env *object.Environment) (bool, error) {
+	obj, err := evalExpression(exp, env)
+	if err != nil {
+		return false, err
+	}
+
+	boolean, ok := obj.(object.Boolean)
+	if !ok {
+		tok := ast.TokenOf(exp)
+		return false, newError(object.TypeMismatchError, tok,
+			"expected condition to be '%s', but got '%s'", object.BooleanObj, obj.Type())
+	}
+
+	return boolean.Value, nil
+}
+
+func evalFnCall(call ast.FnCall, env *object.Environment) (object.Object, error) {
+	callee, err := evalExpression(call.Callee, env)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]object.Object, 0, len(call.Arguments))
+	for _, arg := range call.Arguments {
+		obj, err := evalExpression(arg, env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, obj)
+	}
+
+	switch fn := callee.(type) {
+	case *object.Function:
+		return applyFunction(call, fn, args, env)
+	case *object.Builtin:
+		result := fn.Fn(env.Output(), args...)
+		if errObj, ok := result.(*object.Error); ok {
+			return nil, positionedError(errObj, call.Token)
+		}
+		return result, nil
+	default:
+		return nil, newError(object.NotCallableError, call.Token, "cannot call a value of type %s", callee.Type())
+	}
+}
+
+// applyFunction binds args to the parameters of fn in a scope nested inside
+// the scope fn was defined in, and runs its body there. env is the scope of
+// the call, which counts how many calls are in progress.
+func applyFunction(call ast.FnCall, fn *object.Function, args []object.Object, env *object.Environment) (object.Object, error) {
+	if len(args) != len(fn.Parameters) {
+		return nil, newError(object.ArgumentCountError, call.Token,
+			"function '%s' expects %d arguments, but got %d", fn.Name, len(fn.Parameters), len(args))
+	}
+
+	if env.Depth()+1 >= object.MaxCallDepth {
+		return nil, newError(object.StackOverflowError, call.Token, "stack overflow")
+	}
+
+	fnEnv := object.NewCallEnvironment(fn.Env, env)
+	for i, param := range fn.Parameters {
+		fnEnv.Set(param.Value, args[i])
+	}
+
+	result, err := evalStatements(fn.Statements, fnEnv)
+	if err != nil {
+		if runtimeErr, ok := err.(*object.RuntimeError); ok {
+			frame := object.Frame{Function: fn.Name, Line: call.Token.Line, Col: call.Token.Col}
+			runtimeErr.Object.Trace = append(runtimeErr.Object.Trace, frame)
+		}
+		return nil, err
+	}
+
+	if returnValue, ok := result.(object.ReturnValue); ok {
+		return returnValue.Value, nil
+	}
+
+	return Nil, nil
+}
+
+func evalExpression(exp ast.Expression, env *object.Environment) (object.Object, error) {
+	switch exp := exp.(type) {
+	case ast.Atom:
+		return evalAtom(exp, env)
+	case ast.BinaryExpression:
+		return evalBinaryExpression(exp, env)
+	case ast.FunctionLiteral:
+		return &object.Function{
+			Name:       object.AnonymousFunction,
+			Parameters: exp.Params,
+			Statements: exp.Statements,
+			Env:        env,
+		}, nil
+	case ast.ListLiteral:
+		return evalListLiteral(exp, env)
+	case ast.LogicalExpression:
+		return evalLogicalExpression(exp, env)
+	case ast.UnaryExpression:
+		return evalUnaryExpression(exp, env)
+	case ast.FnCall:
+		return evalFnCall(exp, env)
+	default:
+		tok := ast.TokenOf(exp)
+		return nil, newError(object.UnsupportedError, tok, "cannot evaluate expression '%s'", exp.TokenType())
+	}
+}
+
+func evalAtom(atom ast.Atom, env *object.Environment) (object.Object, error) {
+	switch atom.TokenType() {
+	case token.Int:
+		value, err := strconv.ParseInt(atom.Value, 10, 64)
+		if err != nil {
+			return nil, newError(object.InvalidLiteralError, atom.Token, "cannot use '%s' as an integer", atom.Value)
+		}
+		return object.Integer{Value: value}, nil
+	case token.Float:
+		value, err := strconv.ParseFloat(atom.Value, 64)
+		if err != nil {
+			return nil, newError(object.InvalidLiteralError, atom.Token, "cannot use '%s' as a float", atom.Value)
+		}
+		return object.Float{Value: value}, nil
+	case token.Bool:
+		return nativeBoolToBoolean(atom.Value == "true"), nil
+	case token.String:
+		return object.String{Value: atom.Value}, nil
+	case token.Nil:
+		return Nil, nil
+	case token.Ident:
+		if obj, ok := env.Get(atom.Value); ok {
+			return obj, nil
+		}
+		if builtin, ok := object.GetBuiltinByName(atom.Value); ok {
+			return builtin, nil
+		}
+		return nil, newError(object.UndefinedIdentifierError, atom.Token, "undefined identifier '%s'", atom.Value)
+	default:
+		return nil, newError(object.UnsupportedError, atom.Token, "cannot evaluate atom '%s'", atom.TokenType())
+	}
+}
+
+func evalBinaryExpression(exp ast.BinaryExpression, env *object.Environment) (object.Object, error) {
+	first, err := evalExpression(exp.First, env)
+	if err != nil {
+		return nil, err
+	}
+
+	second, err := evalExpression(exp.Second, env)
+	if err != nil {
+		return nil, err
+	}
+
+	result := object.BinaryOperation(exp.TokenType(), first, second)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, positionedError(errObj, exp.Token)
+	}
+
+	return result, nil
+}
+
+func evalListLiteral(list ast.ListLiteral, env *object.Environment) (object.Object, error) {
+	elements := make([]object.Object, 0, len(list.Elements))
+	for _, exp := range list.Elements {
+		obj, err := evalExpression(exp, env)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, obj)
+	}
+
+	return &object.List{Elements: elements}, nil
+}
+
+// evalLogicalExpression evaluates operands until one of them decides the
+// result: the first false one for &&, or the first true one for ||.
+func evalLogicalExpression(exp ast.LogicalExpression, env *object.Environment) (object.Object, error) {
+	decisive := exp.TokenType() == token.Or
+
+	for _, operand := range exp.Operands {
+		obj, err := evalExpression(operand, env)
+		if err != nil {
+			return nil, err
+		}
+
+		boolean, ok := obj.(object.Boolean)
+		if !ok {
+			return nil, newError(object.TypeMismatchError, exp.Token,
+				"expected operands of '%s' to be '%s', but got '%s'", exp.TokenLiteral(), object.BooleanObj, obj.Type())
+		}
+		if boolean.Value == decisive {
+			return boolean, nil
+		}
+	}
+
+	return nativeBoolToBoolean(!decisive), nil
+}
+
+func evalUnaryExpression(exp ast.UnaryExpression, env *object.Environment) (object.Object, error) {
+	operand, err := evalExpression(exp.Operand, env)
+	if err != nil {
+		return nil, err
+	}
+
+	boolean, ok := operand.(object.Boolean)
+	if !ok {
+		return nil, newError(object.TypeMismatchError, exp.Token,
+			"expected operand of '%s' to be '%s', but got '%s'", exp.TokenLiteral(), object.BooleanObj, operand.Type())
+	}
+
+	return nativeBoolToBoolean(!boolean.Value), nil
+}
+
+// positionedError reports an *object.Error produced by the object package,
+// which does not know where in the source it happened, at tok.
+func positionedError(errObj *object.Error, tok token.Token) error {
+	errObj.Line = tok.Line
+	errObj.Col = tok.Col
+	return &object.RuntimeError{Object: errObj}
+}
+
+func newError(kind object.ErrorKind, tok token.Token, format string, a ...any) error {
+	return &object.RuntimeError{Object: object.NewError(kind, tok, format, a...)}
+}
+
+func nativeBoolToBoolean(b bool) object.Boolean {
+	if b {
+		return True
+	}
+	return False
+}
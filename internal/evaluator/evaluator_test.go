@@ -0,0 +1,720 @@
+package evaluator
+
+import (
+	"io"
+	"testing"
+
+	"github.com/avearmin/simple/internal/lexer"
+	"github.com/avearmin/simple/internal/object"
+	"github.com/avearmin/simple/internal/parser"
+)
+
+func TestEval(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  object.Object
+	}{
+		"assign int": {
+			input: "(:= foo 5)",
+			want:  object.Integer{Value: 5},
+		},
+		"arithmetic": {
+			input: `(:= foo (+ 1 2))
+(= foo (- foo 1))
+(= foo (* foo 6))
+(= foo (/ foo 4))
+(= foo (% foo 2))`,
+			want: object.Integer{Value: 1},
+		},
+		"comparison": {
+			input: `(:= foo 2)
+(:= isBar (<= foo 2))`,
+			want: object.Boolean{Value: true},
+		},
+		"equality of different types": {
+			input: "(:= isBar (== 1 true))",
+			want:  object.Boolean{Value: false},
+		},
+		"nil": {
+			input: "(:= foo nil)",
+			want:  object.Nil{},
+		},
+		"string": {
+			input: `(:= foo "bar\tbaz")`,
+			want:  object.String{Value: "bar\tbaz"},
+		},
+		"float arithmetic": {
+			input: `(:= foo (* 1.5 2.5))
+(= foo (- foo 1e-1))`,
+			want: object.Float{Value: 3.65},
+		},
+		"int promoted to float": {
+			input: "(:= foo (/ 10 4.0))",
+			want:  object.Float{Value: 2.5},
+		},
+		"int and float equality": {
+			input: "(:= foo (== 2 2.0))",
+			want:  object.Boolean{Value: true},
+		},
+		"float modulo": {
+			input: "(:= foo (% 7.5 2))",
+			want:  object.Float{Value: 1.5},
+		},
+		"int to float": {
+			input: "(float 3)",
+			want:  object.Float{Value: 3},
+		},
+		"float to int truncates": {
+			input: "(int 2.9)",
+			want:  object.Integer{Value: 2},
+		},
+		"string to float": {
+			input: `(:= foo "2.5")
+(float foo)`,
+			want: object.Float{Value: 2.5},
+		},
+		"logical and": {
+			input: "(:= foo (&& true (< 1 2) false))",
+			want:  object.Boolean{Value: false},
+		},
+		"logical or": {
+			input: "(:= foo (|| false (> 1 2) true))",
+			want:  object.Boolean{Value: true},
+		},
+		"logical operators short circuit": {
+			input: `(fn fail (return (/ 1 0)))
+(:= foo (&& false (fail)))
+(= foo (|| true (fail)))`,
+			want: object.Boolean{Value: true},
+		},
+		"not": {
+			input: "(:= foo (! (&& true false)))",
+			want:  object.Boolean{Value: true},
+		},
+		"list": {
+			input: `(:= foo (list 1 "two" (list true)))`,
+			want:  &object.List{Elements: []object.Object{object.Integer{Value: 1}, object.String{Value: "two"}, &object.List{Elements: []object.Object{object.Boolean{Value: true}}}}},
+		},
+		"list builtins": {
+			input: `(:= foo (list 1 2))
+(push foo 3 4)
+(set foo 0 (pop foo))
+(:= bar (+ (len foo) (get foo 0)))`,
+			want: object.Integer{Value: 7},
+		},
+		"lists are shared": {
+			input: `(:= foo (list 1))
+(:= bar foo)
+(push bar 2)
+(len foo)`,
+			want: object.Integer{Value: 2},
+		},
+		"slice and concat make new lists": {
+			input: `(:= foo (list 1 2 3))
+(:= bar (concat (slice foo 1 3) (list) foo))
+(set bar 0 0)`,
+			want: &object.List{Elements: []object.Object{
+				object.Integer{Value: 0}, object.Integer{Value: 3},
+				object.Integer{Value: 1}, object.Integer{Value: 2}, object.Integer{Value: 3},
+			}},
+		},
+		"map builtins": {
+			input: `(:= foo (map "a" 1 2 "b" true nil))
+(put foo "a" 10)
+(put foo "c" 3)
+(delete foo 2)
+(:= bar (+ (get foo "a") (len foo)))`,
+			want: object.Integer{Value: 13},
+		},
+		"map membership": {
+			input: `(:= foo (map "a" 1))
+(delete foo "a")
+(:= bar (&& (! (has foo "a")) (has (map false 0) false)))`,
+			want: object.Boolean{Value: true},
+		},
+		"map keys in insertion order": {
+			input: `(:= foo (map "z" 1 "a" 2))
+(put foo 5 3)
+(put foo "z" 4)
+(delete foo "a")
+(put foo "a" 5)
+(keys foo)`,
+			want: &object.List{Elements: []object.Object{object.String{Value: "z"}, object.Integer{Value: 5}, object.String{Value: "a"}}},
+		},
+		"function literal as value": {
+			input: `(:= add (fn (x y) (return (+ x y))))
+(:= foo (add 1 2))`,
+			want: object.Integer{Value: 3},
+		},
+		"function values as arguments": {
+			input: `(fn apply f xs
+    (:= out (list))
+    (for (:= i 0) (< i (len xs)) (= i (+ i 1))
+        (push out (f (get xs i))))
+    (return out))
+(fn double x (return (* x 2)))
+(:= foo (concat (apply double (list 1 2)) (apply (fn (x) (return (+ x 1))) (list 1 2))))`,
+			want: &object.List{Elements: []object.Object{
+				object.Integer{Value: 2}, object.Integer{Value: 4}, object.Integer{Value: 2}, object.Integer{Value: 3},
+			}},
+		},
+		"function literal closure": {
+			input: `(fn counter
+    (:= count 0)
+    (return (fn () (= count (+ count 1)) (return count))))
+(:= next (counter))
+(next)
+(next)
+(:= foo (next))`,
+			want: object.Integer{Value: 3},
+		},
+		"call any expression": {
+			input: `(fn adder x (return (fn (y) (return (+ x y)))))
+(:= fs (list (adder 10)))
+(:= foo (+ ((adder 1) 2) ((get fs 0) 5)))
+((fn () (= foo (+ foo 1))))
+(= foo foo)`,
+			want: object.Integer{Value: 19},
+		},
+		"while loop": {
+			input: `(:= foo 1)
+(while (< foo 100) (= foo (* foo 2)))
+(= foo foo)`,
+			want: object.Integer{Value: 128},
+		},
+		"break and continue": {
+			input: `(:= foo 0)
+(:= i 0)
+(while true
+    (= i (+ i 1))
+    (if (> i 10) (break))
+    (if (== (% i 2) 0) (continue))
+    (= foo (+ foo i)))
+(for (:= j 0) (< j 10) (= j (+ j 1))
+    (if (< j 8) (continue))
+    (= foo (+ foo 100))
+    (break))
+(= foo foo)`,
+			want: object.Integer{Value: 125},
+		},
+		"break from the innermost loop": {
+			input: `(:= foo 0)
+(for (:= i 0) (< i 3) (= i (+ i 1))
+    (while true
+        (= foo (+ foo 1))
+        (break)))
+(= foo foo)`,
+			want: object.Integer{Value: 3},
+		},
+		"for loop with any header": {
+			input: `(:= foo (list))
+(:= i 0)
+(fn next (= i (+ i 1)))
+(:= done false)
+(for (push foo i) (! done) (next)
+    (= done (>= i 2))
+    (push foo i))
+(= foo foo)`,
+			want: &object.List{Elements: []object.Object{
+				object.Integer{Value: 0}, object.Integer{Value: 0}, object.Integer{Value: 1}, object.Integer{Value: 2},
+			}},
+		},
+		"for loop with empty slots": {
+			input: `(:= foo 0)
+(for () () ()
+    (= foo (+ foo 1))
+    (if (== foo 5) (break)))
+(= foo foo)`,
+			want: object.Integer{Value: 5},
+		},
+		"for each over a list": {
+			input: `(:= foo 0)
+(for x in (list 1 2 3)
+    (= foo (+ foo x)))
+(for i x in (list 10 20)
+    (= foo (+ foo (* i x))))
+(= foo foo)`,
+			want: object.Integer{Value: 26},
+		},
+		"for each over a map": {
+			input: `(:= foo (list))
+(:= m (map "a" 1 "b" 2))
+(for k in m (push foo k))
+(for k v in m
+    (push foo v)
+    (put m "c" 3))
+(= foo foo)`,
+			want: &object.List{Elements: []object.Object{
+				object.String{Value: "a"}, object.String{Value: "b"}, object.Integer{Value: 1}, object.Integer{Value: 2},
+			}},
+		},
+		"for each over a range": {
+			input: `(:= foo (list))
+(for i in (range 3) (push foo i))
+(for i in (range 10 0 (- 0 4))
+    (if (== i 6) (continue))
+    (push foo i))
+(for i in (range 1 100)
+    (if (> i 2) (break))
+    (push foo i))
+(= foo foo)`,
+			want: &object.List{Elements: []object.Object{
+				object.Integer{Value: 0}, object.Integer{Value: 1}, object.Integer{Value: 2},
+				object.Integer{Value: 10}, object.Integer{Value: 2},
+				object.Integer{Value: 1}, object.Integer{Value: 2},
+			}},
+		},
+		"for each closures capture each item": {
+			input: `(:= fs (list))
+(for x in (list 1 2)
+    (push fs (fn () (return x))))
+(:= foo (+ ((get fs 0)) (* 10 ((get fs 1)))))`,
+			want: object.Integer{Value: 21},
+		},
+		"return from a for each loop": {
+			input: `(fn first xs
+    (for x in xs (return x))
+    (return nil))
+(:= foo (first (list 7 8)))`,
+			want: object.Integer{Value: 7},
+		},
+		"return from a while loop": {
+			input: `(fn find xs x
+    (:= i 0)
+    (while (< i (len xs))
+        (if (== (get xs i) x) (return i))
+        (= i (+ i 1)))
+    (return nil))
+(:= foo (find (list 4 5 6) 6))`,
+			want: object.Integer{Value: 2},
+		},
+		"string concatenation": {
+			input: `(:= foo "foo")
+(= foo (+ foo "bar"))`,
+			want: object.String{Value: "foobar"},
+		},
+		"string equality": {
+			input: `(:= foo (== "foo" "foo"))`,
+			want:  object.Boolean{Value: true},
+		},
+		"string is never equal to an integer": {
+			input: `(:= foo (== "1" 1))`,
+			want:  object.Boolean{Value: false},
+		},
+		"if": {
+			input: `(:= foo 1)
+(if (== foo 1) (= foo 2))
+(= foo foo)`,
+			want: object.Integer{Value: 2},
+		},
+		"elif": {
+			input: `(:= foo 2)
+(if (== foo 1) (= foo 10)
+elif (== foo 2) (= foo 20) (= foo (+ foo 1))
+else (= foo 30))
+(= foo foo)`,
+			want: object.Integer{Value: 21},
+		},
+		"else": {
+			input: `(:= foo 3)
+(if (== foo 1) (= foo 10)
+elif (== foo 2) (= foo 20)
+else (= foo 30))
+(= foo foo)`,
+			want: object.Integer{Value: 30},
+		},
+		"function call": {
+			input: `(fn addThenDouble x y
+    (:= z (+ x y))
+    (return (* 2 z)))
+(addThenDouble 1 2)`,
+			want: object.Integer{Value: 6},
+		},
+		"function without return": {
+			input: `(fn noop x
+    (:= y x))
+(noop 1)`,
+			want: object.Nil{},
+		},
+		"recursion": {
+			input: `(:= total 1)
+(fn fact n
+    (if (> n 1)
+        (= total (* total n))
+        (:= next (- n 1))
+        (fact next)))
+(fact 5)
+(= total total)`,
+			want: object.Integer{Value: 120},
+		},
+		"recursive calls as expressions": {
+			input: `(fn fact n
+    (if (<= n 1) (return 1))
+    (return (* n (fact (- n 1)))))
+(:= foo (fact 5))`,
+			want: object.Integer{Value: 120},
+		},
+		"nested calls as arguments": {
+			input: `(fn add x y (return (+ x y)))
+(fn mul x y (return (* x y)))
+(:= foo (add 1 (mul 2 3)))`,
+			want: object.Integer{Value: 7},
+		},
+		"call without arguments": {
+			input: `(fn two (return 2))
+(:= foo (+ (two) (int 1.5)))`,
+			want: object.Integer{Value: 3},
+		},
+		"for loop": {
+			input: `(:= sum 0)
+(for (:= i 0) (< i 5) (= i (+ i 1))
+    (= sum (+ sum i)))
+(= sum sum)`,
+			want: object.Integer{Value: 10},
+		},
+		"return from loop": {
+			input: `(fn firstOver limit
+    (for (:= i 0) (< i 100) (= i (+ i 1))
+        (if (> i limit) (return i))))
+(firstOver 41)`,
+			want: object.Integer{Value: 42},
+		},
+		"parameters shadow outer names": {
+			input: `(:= x 1)
+(fn setX x (= x 5))
+(setX 2)
+(= x x)`,
+			want: object.Integer{Value: 1},
+		},
+		"assign in block shadows outer name": {
+			input: `(:= x 1)
+(if true (:= x 2) (= x 3))
+(= x x)`,
+			want: object.Integer{Value: 1},
+		},
+		"reassign in block mutates outer name": {
+			input: `(:= x 1)
+(if true (= x 2))
+(= x x)`,
+			want: object.Integer{Value: 2},
+		},
+		"reassign in function mutates defining scope": {
+			input: `(:= calls 0)
+(fn count x (= calls (+ calls 1)))
+(count 1)
+(count 1)
+(= calls calls)`,
+			want: object.Integer{Value: 2},
+		},
+		"closure": {
+			input: `(:= saved nil)
+(fn makeCounter start
+    (:= count start)
+    (fn bump step
+        (= count (+ count step))
+        (return count))
+    (= saved bump))
+(makeCounter 10)
+(saved 1)
+(saved 2)`,
+			want: object.Integer{Value: 13},
+		},
+		"closures capture separate scopes": {
+			input: `(:= first nil)
+(:= second nil)
+(fn saveFirst start
+    (:= count start)
+    (fn bump step
+        (= count (+ count step))
+        (return count))
+    (= first bump))
+(fn saveSecond start
+    (:= count start)
+    (fn bump step
+        (= count (+ count step))
+        (return count))
+    (= second bump))
+(saveFirst 0)
+(saveSecond 100)
+(first 1)
+(second 1)
+(first 1)`,
+			want: object.Integer{Value: 2},
+		},
+		"mutually recursive local functions": {
+			input: `(fn isEven n
+    (fn even n
+        (if (== n 0) (return true))
+        (return (odd (- n 1))))
+    (fn odd n
+        (if (== n 0) (return false))
+        (return (even (- n 1))))
+    (return (even n)))
+(isEven 10)`,
+			want: object.Boolean{Value: true},
+		},
+		"closure reads a local assigned later": {
+			input: `(fn later
+    (fn get (return value))
+    (:= value 42)
+    (return (get)))
+(later)`,
+			want: object.Integer{Value: 42},
+		},
+		"assignment in block reads the outer name": {
+			input: `(fn inc x
+    (if true
+        (fn get (return x))
+        (:= x (+ x 1))
+        (return (get))))
+(inc 1)`,
+			want: object.Integer{Value: 2},
+		},
+		"functions as arguments": {
+			input: `(:= total 0)
+(fn add x (= total (+ total x)))
+(fn twice f x
+    (f x)
+    (f x))
+(twice add 21)
+(= total total)`,
+			want: object.Integer{Value: 42},
+		},
+		"top level return": {
+			input: `(:= foo 1)
+(return foo)
+(= foo 2)`,
+			want: object.Integer{Value: 1},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := testEval(t, test.input)
+			if err != nil {
+				t.Fatalf("Eval failed with error: %s", err.Error())
+			}
+			if !isEqualObjects(got, test.want) {
+				t.Fatalf("got=%s(%s), want=%s(%s)", got.Type(), got.Inspect(), test.want.Type(), test.want.Inspect())
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  object.Error
+	}{
+		"division by zero": {
+			input: "(:= foo (/ 1 0))",
+			want:  object.Error{Kind: object.DivisionByZeroError, Message: "division by zero", Line: 1, Col: 9},
+		},
+		"modulo by zero": {
+			input: "(:= foo (% 1 0))",
+			want:  object.Error{Kind: object.ModuloByZeroError, Message: "modulo by zero", Line: 1, Col: 9},
+		},
+		"undefined identifier": {
+			input: "(:= foo bar)",
+			want:  object.Error{Kind: object.UndefinedIdentifierError, Message: "undefined identifier 'bar'", Line: 1, Col: 8},
+		},
+		"reassign before assign": {
+			input: "(= foo 1)",
+			want:  object.Error{Kind: object.ReassignBeforeAssignError, Message: "cannot reassign 'foo' before it is assigned", Line: 1, Col: 3},
+		},
+		"block scope ends with block": {
+			input: `(if true (:= foo 1))
+(= foo 2)`,
+			want: object.Error{Kind: object.ReassignBeforeAssignError, Message: "cannot reassign 'foo' before it is assigned", Line: 2, Col: 3},
+		},
+		"type mismatch": {
+			input: "(:= foo (+ true 1))",
+			want:  object.Error{Kind: object.TypeMismatchError, Message: "type mismatch: BOOLEAN + INTEGER", Line: 1, Col: 9},
+		},
+		"float division by zero": {
+			input: "(:= foo (/ 1.5 0))",
+			want:  object.Error{Kind: object.DivisionByZeroError, Message: "division by zero", Line: 1, Col: 9},
+		},
+		"invalid conversion": {
+			input: `(:= foo "abc")
+(int foo)`,
+			want: object.Error{Kind: object.BuiltinError, Message: "cannot convert STRING 'abc' to INTEGER", Line: 2, Col: 1},
+		},
+		"non boolean logical operand": {
+			input: "(:= foo (|| false 1))",
+			want:  object.Error{Kind: object.TypeMismatchError, Message: "expected operands of '||' to be 'BOOLEAN', but got 'INTEGER'", Line: 1, Col: 9},
+		},
+		"non boolean not operand": {
+			input: "(:= foo (! nil))",
+			want:  object.Error{Kind: object.TypeMismatchError, Message: "expected operand of '!' to be 'BOOLEAN', but got 'NIL'", Line: 1, Col: 9},
+		},
+		"index out of range": {
+			input: `(:= foo (list 1 2))
+(:= bar (+ 1 (get foo 2)))`,
+			want: object.Error{Kind: object.IndexOutOfBoundsError, Message: "index 2 out of range for list of length 2", Line: 2, Col: 14},
+		},
+		"slice out of range": {
+			input: `(:= foo (list 1 2))
+(slice foo 1 3)`,
+			want: object.Error{Kind: object.IndexOutOfBoundsError, Message: "slice bounds 1 to 3 out of range for list of length 2", Line: 2, Col: 1},
+		},
+		"pop from empty list": {
+			input: `(:= foo (list))
+(pop foo)`,
+			want: object.Error{Kind: object.IndexOutOfBoundsError, Message: "cannot pop from an empty list", Line: 2, Col: 1},
+		},
+		"wrong list argument type": {
+			input: `(get 1 0)`,
+			want:  object.Error{Kind: object.TypeMismatchError, Message: "expected argument 1 of 'get' to be 'LIST', but got 'INTEGER'", Line: 1, Col: 1},
+		},
+		"key not found": {
+			input: `(:= foo (map "a" 1))
+(get foo "b")`,
+			want: object.Error{Kind: object.KeyNotFoundError, Message: `key "b" not found in map`, Line: 2, Col: 1},
+		},
+		"unhashable key": {
+			input: `(map (list) 1)`,
+			want:  object.Error{Kind: object.TypeMismatchError, Message: "'map' cannot use a value of type 'LIST' as a map key", Line: 1, Col: 1},
+		},
+		"call of a non function expression": {
+			input: `(:= foo (list 1))
+((get foo 0) 1)`,
+			want: object.Error{Kind: object.NotCallableError, Message: "cannot call a value of type INTEGER", Line: 2, Col: 1},
+		},
+		"anonymous function argument count": {
+			input: `((fn (x) (return x)))`,
+			want:  object.Error{Kind: object.ArgumentCountError, Message: "function '<anonymous>' expects 1 arguments, but got 0", Line: 1, Col: 1},
+		},
+		"for each over a non collection": {
+			input: `(for x in 5 (print x))`,
+			want:  object.Error{Kind: object.TypeMismatchError, Message: "cannot iterate over a value of type INTEGER", Line: 1, Col: 10},
+		},
+		"range with a zero step": {
+			input: `(range 0 1 0)`,
+			want:  object.Error{Kind: object.BuiltinError, Message: "'range' step cannot be zero", Line: 1, Col: 1},
+		},
+		"string type mismatch": {
+			input: `(:= foo (+ "foo" 1))`,
+			want:  object.Error{Kind: object.TypeMismatchError, Message: "type mismatch: STRING + INTEGER", Line: 1, Col: 9},
+		},
+		"unsupported string operator": {
+			input: `(:= foo (- "foo" "bar"))`,
+			want:  object.Error{Kind: object.UnsupportedError, Message: "operator '-' is not supported for STRING", Line: 1, Col: 9},
+		},
+		"non boolean condition": {
+			input: "(if 1 (:= foo 1))",
+			want:  object.Error{Kind: object.TypeMismatchError, Message: "expected condition to be 'BOOLEAN', but got 'INTEGER'", Line: 1, Col: 4},
+		},
+		"wrong number of arguments": {
+			input: `(fn add x y (return (+ x y)))
+(add 1)`,
+			want: object.Error{Kind: object.ArgumentCountError, Message: "function 'add' expects 2 arguments, but got 1", Line: 2, Col: 1},
+		},
+		"not callable": {
+			input: `(:= foo 1)
+(foo 1)`,
+			want: object.Error{Kind: object.NotCallableError, Message: "cannot call a value of type INTEGER", Line: 2, Col: 1},
+		},
+		"stack overflow": {
+			input: `(fn forever x (forever x))
+(forever 1)`,
+			want: object.Error{Kind: object.StackOverflowError, Message: "stack overflow", Line: 1, Col: 15},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := testEval(t, test.input)
+			if err == nil {
+				t.Fatalf("expected error %q, but got none", test.want.Message)
+			}
+			runtimeErr, ok := err.(*object.RuntimeError)
+			if !ok {
+				t.Fatalf("expected *object.RuntimeError, but got %T", err)
+			}
+			got := runtimeErr.Object
+			if got.Kind != test.want.Kind || got.Message != test.want.Message || got.Line != test.want.Line || got.Col != test.want.Col {
+				t.Fatalf("got=%+v, want=%+v", *got, test.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrorTrace(t *testing.T) {
+	input := `(fn divide x y
+    (return (/ x y)))
+(fn half x
+    (:= zero 0)
+    (divide x zero))
+(half 4)`
+	want := []object.Frame{
+		{Function: "divide", Line: 5, Col: 5},
+		{Function: "half", Line: 6, Col: 1},
+	}
+
+	_, err := testEval(t, input)
+	runtimeErr, ok := err.(*object.RuntimeError)
+	if !ok {
+		t.Fatalf("expected *object.RuntimeError, but got %T", err)
+	}
+
+	got := runtimeErr.Object.Trace
+	if len(got) != len(want) {
+		t.Fatalf("len(want)=%d, len(got)=%d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%d: got=%+v, want=%+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEvalErrorTraceRepeatedFrames(t *testing.T) {
+	input := `(fn deep n (return (deep (+ n 1))))
+(deep 0)`
+	want := `stack overflow on line 1 col 20
+	in deep called on line 1 col 20
+	... 1021 more calls
+	in deep called on line 2 col 1`
+
+	_, err := testEval(t, input)
+	if err == nil {
+		t.Fatalf("expected a stack overflow, but got none")
+	}
+	if got := err.Error(); got != want {
+		t.Fatalf("got=%q, want=%q", got, want)
+	}
+}
+
+func testEval(t *testing.T, input string) (object.Object, error) {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("Parsing failed with error: %s", err.Error())
+	}
+
+	return Eval(program, object.NewEnvironment(io.Discard))
+}
+
+// isEqualObjects compares objects by value, including lists, which are
+// pointers.
+func isEqualObjects(first, second object.Object) bool {
+	firstList, ok := first.(*object.List)
+	if !ok {
+		return first == second
+	}
+	secondList, ok := second.(*object.List)
+	if !ok || len(firstList.Elements) != len(secondList.Elements) {
+		return false
+	}
+	for i := range firstList.Elements {
+		if !isEqualObjects(firstList.Elements[i], secondList.Elements[i]) {
+			return false
+		}
+	}
+	return true
+}
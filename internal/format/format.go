@@ -0,0 +1,416 @@
+// Package format prints Simple programs in their canonical layout.
+//
+// Every statement of a block goes on a line of its own, indented four spaces
+// further than the statement the block belongs to, and the closing
+// parenthesis follows the last statement of the block. The elif and else of
+// a conditional line up with its if. Single spaces separate everything else.
+// Comments are kept where they were, either on a line of their own or at the
+// end of the line they followed, and a blank line between two statements is
+// kept as well.
+package format
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/lexer"
+	"github.com/avearmin/simple/internal/parser"
+	"github.com/avearmin/simple/internal/token"
+)
+
+const indentation = "    "
+
+// Source parses input and returns it in the canonical layout. If input has
+// syntax errors, they are returned instead.
+func Source(input string) (string, error) {
+	program, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := Fprint(&b, program); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Fprint writes program to w in the canonical layout, along with its
+// comments.
+func Fprint(w io.Writer, program *ast.Program) error {
+	p := printer{comments: program.Comments, atLineStart: true}
+	p.program(program)
+
+	_, err := io.WriteString(w, p.b.String())
+	return err
+}
+
+// separator is what the printer puts between the last thing it wrote and
+// the next.
+type separator int
+
+const (
+	none separator = iota
+	space
+	newline
+
+	// paragraph is a newline that keeps a blank line from the input.
+	paragraph
+)
+
+type printer struct {
+	b strings.Builder
+
+	// comments are the comments not yet written.
+	comments []token.Comment
+
+	indent int
+	sep    separator
+
+	// lastLine is the line of the input that the last thing written ended
+	// on.
+	lastLine int
+
+	// atLineStart is whether nothing has been written on the current line.
+	atLineStart bool
+}
+
+func (p *printer) program(program *ast.Program) {
+	for _, stmt := range program.Statements {
+		p.sep = paragraph
+		p.statement(stmt)
+	}
+
+	p.sep = paragraph
+	p.flush(math.MaxInt, 0)
+	if !p.atLineStart {
+		p.b.WriteByte('\n')
+	}
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case ast.AssignStatement:
+		p.token(stmt.LParen, "(")
+		p.token(stmt.Token, stmt.Token.Literal)
+		p.sep = space
+		p.atom(stmt.Name)
+		p.sep = space
+		p.expression(stmt.Value)
+		p.token(stmt.RParen, ")")
+	case ast.ReassignStatement:
+		p.token(stmt.LParen, "(")
+		p.token(stmt.Token, stmt.Token.Literal)
+		p.sep = space
+		p.atom(stmt.Name)
+		p.sep = space
+		p.expression(stmt.Value)
+		p.token(stmt.RParen, ")")
+	case ast.ConditionalStatement:
+		p.conditional(stmt)
+	case ast.FunctionAssignStatement:
+		p.token(stmt.LParen, "(")
+		p.token(stmt.Token, stmt.Token.Literal)
+		p.sep = space
+		p.atom(stmt.Name)
+		p.atoms(stmt.Params)
+		p.block(stmt.Statements)
+		p.token(stmt.RParen, ")")
+	case ast.ReturnStatement:
+		p.token(stmt.LParen, "(")
+		p.token(stmt.Token, stmt.Token.Literal)
+		p.sep = space
+		p.expression(stmt.Value)
+		p.token(stmt.RParen, ")")
+	case ast.ForLoopStatement:
+		p.token(stmt.LParen, "(")
+		p.token(stmt.Token, stmt.Token.Literal)
+		p.sep = space
+		p.optionalStatement(stmt.Initalizer)
+		p.sep = space
+		if stmt.Condition == nil {
+			p.write("()")
+		} else {
+			p.expression(stmt.Condition)
+		}
+		p.sep = space
+		p.optionalStatement(stmt.Update)
+		p.block(stmt.Statements)
+		p.token(stmt.RParen, ")")
+	case ast.ForEachStatement:
+		p.token(stmt.LParen, "(")
+		p.token(stmt.Token, stmt.Token.Literal)
+		p.atoms(stmt.Names)
+		p.sep = space
+		p.write("in")
+		p.sep = space
+		p.expression(stmt.Iterable)
+		p.block(stmt.Statements)
+		p.token(stmt.RParen, ")")
+	case ast.WhileStatement:
+		p.token(stmt.LParen, "(")
+		p.token(stmt.Token, stmt.Token.Literal)
+		p.sep = space
+		p.expression(stmt.Condition)
+		p.block(stmt.Statements)
+		p.token(stmt.RParen, ")")
+	case ast.BreakStatement:
+		p.token(stmt.LParen, "(")
+		p.token(stmt.Token, stmt.Token.Literal)
+		p.token(stmt.RParen, ")")
+	case ast.ContinueStatement:
+		p.token(stmt.LParen, "(")
+		p.token(stmt.Token, stmt.Token.Literal)
+		p.token(stmt.RParen, ")")
+	case ast.FnCall:
+		p.fnCall(stmt)
+	}
+}
+
+// conditional writes a conditional statement with each of its elif and else
+// blocks starting a line of its own. An empty block at the end needs a space
+// before the closing parenthesis, as the parser expects a delimiter after the
+// condition or keyword that starts the block.
+func (p *printer) conditional(stmt ast.ConditionalStatement) {
+	p.token(stmt.LParen, "(")
+	p.token(stmt.Token, stmt.Token.Literal)
+	p.sep = space
+	p.expression(stmt.IfCondition)
+	p.block(stmt.IfStatements)
+
+	last := stmt.IfStatements
+	for _, elif := range stmt.ElifBlocks {
+		p.sep = newline
+		p.token(elif.Token, elif.Token.Literal)
+		p.sep = space
+		p.expression(elif.Condition)
+		p.block(elif.Statements)
+		last = elif.Statements
+	}
+
+	if stmt.ElseBlock.Token.Type == token.Else {
+		p.sep = newline
+		p.token(stmt.ElseBlock.Token, stmt.ElseBlock.Token.Literal)
+		p.block(stmt.ElseBlock.Statements)
+		last = stmt.ElseBlock.Statements
+	}
+
+	if len(last) == 0 {
+		p.sep = space
+	}
+	p.token(stmt.RParen, ")")
+}
+
+// block writes each of stmts on a line of its own, one level further in.
+func (p *printer) block(stmts []ast.Statement) {
+	p.indent++
+	for i, stmt := range stmts {
+		if i == 0 {
+			p.sep = newline
+		} else {
+			p.sep = paragraph
+		}
+		p.statement(stmt)
+	}
+	p.indent--
+}
+
+// optionalStatement writes stmt, or an empty slot if it is nil.
+func (p *printer) optionalStatement(stmt ast.Statement) {
+	if stmt == nil {
+		p.write("()")
+		return
+	}
+	p.statement(stmt)
+}
+
+func (p *printer) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case ast.Atom:
+		p.atom(exp)
+	case ast.BinaryExpression:
+		p.token(exp.LParen, "(")
+		p.token(exp.Token, exp.Token.Literal)
+		p.sep = space
+		p.expression(exp.First)
+		p.sep = space
+		p.expression(exp.Second)
+		p.token(exp.RParen, ")")
+	case ast.FunctionLiteral:
+		p.token(exp.LParen, "(")
+		p.token(exp.Token, exp.Token.Literal)
+		p.sep = space
+		p.write("(")
+		for i, param := range exp.Params {
+			if i > 0 {
+				p.sep = space
+			}
+			p.atom(param)
+		}
+		p.write(")")
+		p.block(exp.Statements)
+		p.token(exp.RParen, ")")
+	case ast.ListLiteral:
+		p.token(exp.LParen, "(")
+		p.token(exp.Token, exp.Token.Literal)
+		p.expressions(exp.Elements)
+		p.token(exp.RParen, ")")
+	case ast.LogicalExpression:
+		p.token(exp.LParen, "(")
+		p.token(exp.Token, exp.Token.Literal)
+		p.expressions(exp.Operands)
+		p.token(exp.RParen, ")")
+	case ast.UnaryExpression:
+		p.token(exp.LParen, "(")
+		p.token(exp.Token, exp.Token.Literal)
+		p.sep = space
+		p.expression(exp.Operand)
+		p.token(exp.RParen, ")")
+	case ast.FnCall:
+		p.fnCall(exp)
+	}
+}
+
+// expressions writes each of exps after a space.
+func (p *printer) expressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		p.sep = space
+		p.expression(exp)
+	}
+}
+
+func (p *printer) fnCall(call ast.FnCall) {
+	p.token(call.LParen, "(")
+	p.expression(call.Callee)
+	p.expressions(call.Arguments)
+	p.token(call.RParen, ")")
+}
+
+func (p *printer) atom(atom ast.Atom) {
+	if atom.Token.Type == token.String {
+		p.token(atom.Token, quote(atom.Value))
+		return
+	}
+	p.token(atom.Token, atom.Value)
+}
+
+// atoms writes each of atoms after a space.
+func (p *printer) atoms(atoms []ast.Atom) {
+	for _, atom := range atoms {
+		p.sep = space
+		p.atom(atom)
+	}
+}
+
+// token writes text, which is the source of tok, after the comments before
+// tok and the pending separator.
+func (p *printer) token(tok token.Token, text string) {
+	p.flush(tok.Line, tok.Col)
+	p.separate(tok.Line, text)
+	p.b.WriteString(text)
+
+	p.lastLine = tok.EndLine
+	p.atLineStart = false
+	p.sep = none
+}
+
+// write writes text, which is not the source of any token the syntax tree
+// keeps, after the pending separator.
+func (p *printer) write(text string) {
+	p.separate(p.lastLine, text)
+	p.b.WriteString(text)
+
+	p.atLineStart = false
+	p.sep = none
+}
+
+// separate writes the pending separator before text, which begins on line of
+// the input. Text that has to go on a new line in the middle of a statement,
+// after a line comment, is indented one level further than the statement,
+// unless it is the statement's closing parenthesis.
+func (p *printer) separate(line int, text string) {
+	switch {
+	case p.sep >= newline:
+		p.lineBreak(line, p.indent)
+	case p.atLineStart && text == ")":
+		p.lineBreak(line, p.indent)
+	case p.atLineStart:
+		p.lineBreak(line, p.indent+1)
+	case p.sep == space:
+		p.b.WriteByte(' ')
+	}
+}
+
+// flush writes the comments that come before line and col in the input. A
+// comment that was on the same line as what came before it stays at the end
+// of that line, and any other goes on a line of its own.
+func (p *printer) flush(line, col int) {
+	for len(p.comments) > 0 && isBefore(p.comments[0], line, col) {
+		comment := p.comments[0]
+		p.comments = p.comments[1:]
+
+		switch {
+		case comment.Line == p.lastLine && !p.atLineStart:
+			p.b.WriteByte(' ')
+		case p.sep >= newline:
+			p.lineBreak(comment.Line, p.indent)
+		default:
+			p.lineBreak(comment.Line, p.indent+1)
+		}
+		p.b.WriteString(comment.Text)
+		p.lastLine = comment.Line + strings.Count(comment.Text, "\n")
+
+		if strings.HasPrefix(comment.Text, ";") {
+			p.b.WriteByte('\n')
+			p.atLineStart = true
+		} else {
+			p.atLineStart = false
+		}
+	}
+}
+
+// lineBreak starts a new line indented to level, unless nothing has been
+// written on the current line yet. Before a statement, one blank line is
+// kept if there was at least one before line in the input.
+func (p *printer) lineBreak(line, level int) {
+	if !p.atLineStart {
+		p.b.WriteByte('\n')
+	}
+	if p.sep == paragraph && line > p.lastLine+1 && p.b.Len() > 0 {
+		p.b.WriteByte('\n')
+	}
+	p.b.WriteString(strings.Repeat(indentation, level))
+	p.atLineStart = false
+}
+
+func isBefore(comment token.Comment, line, col int) bool {
+	return comment.Line < line || (comment.Line == line && comment.Col < col)
+}
+
+// quote returns value as a string literal, escaping the characters that
+// cannot appear in one as they are.
+func quote(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < ' ' || r == 0x7f:
+			b.WriteString(`\u{` + strconv.FormatInt(int64(r), 16) + `}`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
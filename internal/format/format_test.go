@@ -0,0 +1,190 @@
+package format
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/lexer"
+	"github.com/avearmin/simple/internal/parser"
+	"github.com/avearmin/simple/internal/token"
+)
+
+func TestSource(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"single spaces": {
+			input: "(:=   x\t(+ 1\n2))  (= x (list))",
+			want:  "(:= x (+ 1 2))\n(= x (list))\n",
+		},
+		"function body": {
+			input: "(fn addThenDouble x y (:= z (+ x y)) (return (* 2 z)))",
+			want: `(fn addThenDouble x y
+    (:= z (+ x y))
+    (return (* 2 z)))
+`,
+		},
+		"for loop body": {
+			input: "(for (:= i 0) (< i 5) (= i (+ i 1)) (f i))\n(for () () ()\n(break))",
+			want: `(for (:= i 0) (< i 5) (= i (+ i 1))
+    (f i))
+(for () () ()
+    (break))
+`,
+		},
+		"nested bodies": {
+			input: "(for x in xs (while (f x) (if (g) (break))))",
+			want: `(for x in xs
+    (while (f x)
+        (if (g)
+            (break))))
+`,
+		},
+		"elif and else line up with if": {
+			input: "(if a (f) elif b (g) (h) else (i))",
+			want: `(if a
+    (f)
+elif b
+    (g)
+    (h)
+else
+    (i))
+`,
+		},
+		"empty blocks": {
+			input: "(while x)\n(for k v in m)\n(:= f (fn ()))\n(if a (f) else )",
+			want: `(while x)
+(for k v in m)
+(:= f (fn ()))
+(if a
+    (f)
+else )
+`,
+		},
+		"function literal": {
+			input: "(:= add (fn (x y) (return (+ x y))))",
+			want: `(:= add (fn (x y)
+    (return (+ x y))))
+`,
+		},
+		"strings are quoted": {
+			input: `(print "a\"b\\c\n" "tab	\u{7}")`,
+			want:  `(print "a\"b\\c\n" "tab\t\u{7}")` + "\n",
+		},
+		"blank lines between statements": {
+			input: "(:= x 1)\n\n\n\n(:= y 2)\n(:= z 3)",
+			want:  "(:= x 1)\n\n(:= y 2)\n(:= z 3)\n",
+		},
+		"comments on their own line": {
+			input: "; first\n(:= x 1)\n  #| block |#\n(fn f\n; inside\n(g))\n; last",
+			want: `; first
+(:= x 1)
+#| block |#
+(fn f
+    ; inside
+    (g))
+; last
+`,
+		},
+		"comments at the end of a line": {
+			input: "(:= x 1) ; one\n(while x ; loop\n(f) #| call |#\n)",
+			want: `(:= x 1) ; one
+(while x ; loop
+    (f) #| call |#)
+`,
+		},
+		"comment inside an expression": {
+			input: "(print a ; why\n b)",
+			want: `(print a ; why
+    b)
+`,
+		},
+		"comment before a closing parenthesis": {
+			input: "(while x\n(f)\n; done\n)",
+			want: `(while x
+    (f)
+    ; done
+)
+`,
+		},
+		"empty program": {
+			input: "",
+			want:  "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Source(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Fatalf("got=\n%s\nwant=\n%s", got, test.want)
+			}
+
+			again, err := Source(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != got {
+				t.Fatalf("formatting is not idempotent, got=\n%s\nthen=\n%s", got, again)
+			}
+
+			if !reflect.DeepEqual(parse(t, test.input), parse(t, got)) {
+				t.Fatalf("the formatted program does not parse to the same syntax tree")
+			}
+		})
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	if _, err := Source("(:= x"); err == nil {
+		t.Fatal("expected a syntax error, but formatting succeeded")
+	}
+}
+
+// parse parses input, and clears everything from the syntax tree that
+// formatting is allowed to change: the positions of tokens, and comments.
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	program, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	program.Comments = nil
+	clearPositions(reflect.ValueOf(program).Elem())
+
+	return program
+}
+
+func clearPositions(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		// The values inside interfaces cannot be set, so a copy is
+		// cleared and put back.
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		clearPositions(elem)
+		v.Set(elem)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearPositions(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(token.Token{}) {
+			tok := v.Interface().(token.Token)
+			v.Set(reflect.ValueOf(token.Token{Type: tok.Type, Literal: tok.Literal}))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			clearPositions(v.Field(i))
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package lexer
+
+import (
+	"github.com/avearmin/simple/internal/diagnostics"
+	"github.com/avearmin/simple/internal/token"
+)
+
+// Diagnose explains why tok, an Illegal token, could not be lexed.
+func Diagnose(tok token.Token) *diagnostics.Diagnostic {
+	span := diagnostics.Span{Start: diagnostics.Position{Line: tok.Line, Col: tok.Col}, End: literalEnd(tok)}
+
+	switch {
+	case len(tok.Literal) > 0 && tok.Literal[0] == '"':
+		return diagnoseString(tok, span)
+	case len(tok.Literal) > 1 && tok.Literal[:2] == "#|":
+		d := diagnostics.New(diagnostics.UnterminatedComment, span, "block comment is not terminated").
+			Note("block comments nest, so every '#|' needs a '|#' of its own")
+		d.Fix = insertAt(span.End, "|#")
+		return d
+	case len(tok.Literal) > 0 && isDigit(tok.Literal[0]):
+		return diagnostics.New(diagnostics.InvalidLiteral, span, "invalid number '%s'", tok.Literal)
+	default:
+		return diagnostics.New(diagnostics.InvalidLiteral, span, "invalid identifier '%s'", tok.Literal).
+			Note("identifiers may only contain letters")
+	}
+}
+
+// diagnoseString explains why a string literal is illegal: it is either not
+// terminated, or has an invalid escape sequence in it.
+func diagnoseString(tok token.Token, span diagnostics.Span) *diagnostics.Diagnostic {
+	l := New(tok.Literal)
+	l.readChar() // skip the opening quote
+
+	for l.char != '"' {
+		switch l.char {
+		case 0:
+			d := diagnostics.New(diagnostics.UnterminatedString, span, "string is not terminated")
+			d.Fix = insertAt(span.End, `"`)
+			return d
+		case '\\':
+			start := l.position(tok)
+			escapePos := l.pos
+			l.readChar()
+			if l.char == 0 {
+				continue
+			}
+			if _, ok := l.readEscape(); !ok {
+				escape := diagnostics.Span{Start: start, End: l.position(tok)}
+				return diagnostics.New(diagnostics.InvalidEscape, escape,
+					"invalid escape sequence '%s'", tok.Literal[escapePos:l.pos]).
+					Note(`the valid escape sequences are \n, \t, \", \\ and \u{...} with 1 to 6 hex digits`)
+			}
+		default:
+			l.readChar()
+		}
+	}
+
+	return diagnostics.New(diagnostics.InvalidLiteral, span, "invalid string %s", tok.Literal)
+}
+
+// position returns where in the source the lexer is, given that it is lexing
+// the literal of tok.
+func (l *Lexer) position(tok token.Token) diagnostics.Position {
+	if l.line == 1 {
+		return diagnostics.Position{Line: tok.Line, Col: tok.Col + l.col}
+	}
+	return diagnostics.Position{Line: tok.Line + l.line - 1, Col: l.col}
+}
+
+// literalEnd returns the position just after the literal of tok, which may
+// span several lines.
+func literalEnd(tok token.Token) diagnostics.Position {
+	end := diagnostics.Position{Line: tok.Line, Col: tok.Col}
+	for i := 0; i < len(tok.Literal); i++ {
+		if tok.Literal[i] == '\n' {
+			end.Line++
+			end.Col = 0
+		} else {
+			end.Col++
+		}
+	}
+	return end
+}
+
+func insertAt(pos diagnostics.Position, text string) *diagnostics.Fix {
+	return &diagnostics.Fix{
+		Message:     "insert '" + text + "'",
+		Span:        diagnostics.Span{Start: pos, End: pos},
+		Replacement: text,
+	}
+}
@@ -1,6 +1,10 @@
 package lexer
 
 import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/avearmin/simple/internal/token"
 )
 
@@ -11,6 +15,10 @@ type Lexer struct {
 	char    byte
 	line    int
 	col     int
+
+	// comments are the comments read since the last token that is not a
+	// Delimiter, which the next such token carries.
+	comments []token.Comment
 }
 
 func New(input string) *Lexer {
@@ -27,6 +35,7 @@ func New(input string) *Lexer {
 
 func (l *Lexer) readChar() {
 	if l.nextPos >= len(l.input) {
+		l.pos = len(l.input)
 		l.char = 0
 		return
 	}
@@ -43,12 +52,40 @@ func (l *Lexer) readChar() {
 	l.char = l.input[l.pos]
 }
 
+// NextToken returns the next token of the input. Comments are read along with
+// the whitespace around them, and are attached to the first token after them
+// that is not a Delimiter.
 func (l *Lexer) NextToken() token.Token {
+	offset := l.pos
+	tok := l.nextToken()
+
+	if tok.Type == token.Illegal && strings.HasPrefix(tok.Literal, "#|") {
+		// An unterminated block comment runs to the end of the input, but
+		// starts after any whitespace before it.
+		offset = len(l.input) - len(tok.Literal)
+	}
+	l.setSpan(&tok, offset)
+
+	if tok.Type != token.Delimiter && len(l.comments) > 0 {
+		tok.Comments = l.comments
+		l.comments = nil
+	}
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
 	line := l.line
 	col := l.col
 
+	if l.atTrivia() {
+		if unterminated, ok := l.readTrivia(); !ok {
+			return token.NewFromString(token.Illegal, unterminated.Text, unterminated.Line, unterminated.Col)
+		}
+		return token.NewFromString(token.Delimiter, "", line, col)
+	}
+
 	switch l.char {
 	case '(':
 		tok = token.NewFromByte(token.LParen, l.char, line, col)
@@ -65,7 +102,7 @@ func (l *Lexer) NextToken() token.Token {
 	case '%':
 		tok = token.NewFromByte(token.Modulo, l.char, line, col)
 	case '|':
-		if l.input[l.nextPos] == '|' {
+		if l.peekChar() == '|' {
 			pos := l.pos
 
 			l.readChar()
@@ -76,7 +113,7 @@ func (l *Lexer) NextToken() token.Token {
 			return tok
 		}
 	case '&':
-		if l.input[l.nextPos] == '&' {
+		if l.peekChar() == '&' {
 			pos := l.pos
 
 			l.readChar()
@@ -87,7 +124,7 @@ func (l *Lexer) NextToken() token.Token {
 			return tok
 		}
 	case '!':
-		if l.input[l.nextPos] == '=' {
+		if l.peekChar() == '=' {
 			pos := l.pos
 
 			l.readChar()
@@ -96,11 +133,11 @@ func (l *Lexer) NextToken() token.Token {
 			notEqualsOp := l.input[pos:l.pos]
 			tok = token.NewFromString(token.NotEquals, notEqualsOp, line, col)
 			return tok
-		} else if isWhitespace(l.input[l.nextPos]) {
+		} else if isWhitespace(l.peekChar()) {
 			tok = token.NewFromByte(token.Not, l.char, line, col)
 		}
 	case '<':
-		if l.input[l.nextPos] == '=' {
+		if l.peekChar() == '=' {
 			pos := l.pos
 
 			l.readChar()
@@ -109,11 +146,11 @@ func (l *Lexer) NextToken() token.Token {
 			lessThanOrEqualsOP := l.input[pos:l.pos]
 			tok = token.NewFromString(token.LessThanOrEquals, lessThanOrEqualsOP, line, col)
 			return tok
-		} else if isWhitespace(l.input[l.nextPos]) {
+		} else if isWhitespace(l.peekChar()) {
 			tok = token.NewFromByte(token.LessThan, l.char, line, col)
 		}
 	case '>':
-		if l.input[l.nextPos] == '=' {
+		if l.peekChar() == '=' {
 			pos := l.pos
 
 			l.readChar()
@@ -122,11 +159,11 @@ func (l *Lexer) NextToken() token.Token {
 			greaterThanOrEqualsOP := l.input[pos:l.pos]
 			tok = token.NewFromString(token.GreaterThanOrEquals, greaterThanOrEqualsOP, line, col)
 			return tok
-		} else if isWhitespace(l.input[l.nextPos]) {
+		} else if isWhitespace(l.peekChar()) {
 			tok = token.NewFromByte(token.GreaterThan, l.char, line, col)
 		}
 	case '=':
-		if l.input[l.nextPos] == '=' {
+		if l.peekChar() == '=' {
 			pos := l.pos
 
 			l.readChar()
@@ -135,11 +172,11 @@ func (l *Lexer) NextToken() token.Token {
 			equalsOp := l.input[pos:l.pos]
 			tok = token.NewFromString(token.Equals, equalsOp, line, col)
 			return tok
-		} else if isWhitespace(l.input[l.nextPos]) {
+		} else if isWhitespace(l.peekChar()) {
 			tok = token.NewFromByte(token.Reassign, l.char, line, col)
 		}
 	case ':':
-		if l.input[l.nextPos] == '=' {
+		if l.peekChar() == '=' {
 			pos := l.pos
 
 			l.readChar()
@@ -149,9 +186,12 @@ func (l *Lexer) NextToken() token.Token {
 			tok = token.NewFromString(token.Assign, assignOp, line, col)
 			return tok
 		}
-	case ' ', '\t', '\n', '\r':
-		l.readWhitespaces()
-		tok = token.NewFromString(token.Delimiter, "", line, col)
+	case '"':
+		if str, ok := l.readString(); ok {
+			tok = token.NewFromString(token.String, str, line, col)
+		} else {
+			tok = token.NewFromString(token.Illegal, str, line, col)
+		}
 		return tok
 	case 0:
 		tok = token.NewFromString(token.EOF, "", line, col)
@@ -163,6 +203,8 @@ func (l *Lexer) NextToken() token.Token {
 			tok = token.NewFromString(token.Nil, ident, line, col)
 		} else if isIdentInt(ident) {
 			tok = token.NewFromString(token.Int, ident, line, col)
+		} else if isIdentFloat(ident) {
+			tok = token.NewFromString(token.Float, ident, line, col)
 		} else if isIdentBool(ident) {
 			tok = token.NewFromString(token.Bool, ident, line, col)
 		} else if isIdentValid(ident) {
@@ -178,22 +220,184 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func (l *Lexer) readWhitespaces() string {
-	pos := l.pos
-	for isWhitespace(l.char) {
+// setSpan sets the offsets and end position of tok, which starts at offset
+// and ends where the lexer is now. The end is counted from the start of the
+// token, rather than taken from the lexer, which does not move past the last
+// character of the input.
+func (l *Lexer) setSpan(tok *token.Token, offset int) {
+	tok.Offset = offset
+	tok.EndOffset = l.pos
+	tok.EndLine = tok.Line
+	tok.EndCol = tok.Col
+
+	for _, c := range []byte(l.input[offset:l.pos]) {
+		if c == '\n' {
+			tok.EndLine++
+			tok.EndCol = 0
+		} else {
+			tok.EndCol++
+		}
+	}
+}
+
+// peekChar returns the character after the current one, or 0 at the end of
+// the input.
+func (l *Lexer) peekChar() byte {
+	if l.nextPos >= len(l.input) {
+		return 0
+	}
+	return l.input[l.nextPos]
+}
+
+// atTrivia reports whether the lexer is at whitespace or the start of a
+// comment.
+func (l *Lexer) atTrivia() bool {
+	return isWhitespace(l.char) || l.char == ';' || (l.char == '#' && l.peekChar() == '|')
+}
+
+// readTrivia reads a run of whitespace and comments, which separates tokens
+// as whitespace alone does. If the run ends in a block comment that is not
+// terminated, it reports false and returns that comment.
+func (l *Lexer) readTrivia() (token.Comment, bool) {
+	for l.atTrivia() {
+		if isWhitespace(l.char) {
+			l.readChar()
+			continue
+		}
+
+		comment, ok := l.readComment()
+		if !ok {
+			return comment, false
+		}
+		l.comments = append(l.comments, comment)
+	}
+	return token.Comment{}, true
+}
+
+// readComment reads a line comment, from a ';' up to the end of the line, or
+// a block comment, from a '#|' to the matching '|#'. Block comments nest, so
+// one can comment out code that contains another. It reports false if a
+// block comment is not terminated.
+func (l *Lexer) readComment() (token.Comment, bool) {
+	pos, line, col := l.pos, l.line, l.col
+
+	if l.char == ';' {
+		for l.char != '\n' && l.char != 0 {
+			l.readChar()
+		}
+		return token.Comment{Text: l.input[pos:l.pos], Line: line, Col: col}, true
+	}
+
+	depth := 0
+	for {
+		switch {
+		case l.char == 0:
+			return token.Comment{Text: l.input[pos:], Line: line, Col: col}, false
+		case l.char == '#' && l.peekChar() == '|':
+			depth++
+			l.readChar()
+		case l.char == '|' && l.peekChar() == '#':
+			depth--
+			l.readChar()
+		}
 		l.readChar()
+
+		if depth == 0 {
+			return token.Comment{Text: l.input[pos:l.pos], Line: line, Col: col}, true
+		}
 	}
-	return l.input[pos:l.pos]
 }
 
 func (l *Lexer) readIdent() string {
 	pos := l.pos
-	for !isWhitespace(l.char) && l.char != ')' {
+	for !isWhitespace(l.char) && l.char != ')' && l.char != ';' && l.char != 0 {
 		l.readChar()
 	}
 	return l.input[pos:l.pos]
 }
 
+// readString reads a double-quoted string and returns its value with every
+// escape sequence replaced. If the string is not terminated, or contains an
+// invalid escape sequence, it reports false and returns the source text.
+func (l *Lexer) readString() (string, bool) {
+	pos := l.pos
+	l.readChar() // skip the opening quote
+
+	var value strings.Builder
+	valid := true
+	for l.char != '"' {
+		switch l.char {
+		case 0:
+			return l.input[pos:l.pos], false
+		case '\\':
+			l.readChar()
+			if r, ok := l.readEscape(); ok {
+				value.WriteRune(r)
+			} else {
+				valid = false
+			}
+		default:
+			value.WriteByte(l.char)
+			l.readChar()
+		}
+	}
+	l.readChar() // skip the closing quote
+
+	if !valid {
+		return l.input[pos:l.pos], false
+	}
+	return value.String(), true
+}
+
+// readEscape reads the escape sequence following a backslash and returns the
+// character it stands for.
+func (l *Lexer) readEscape() (rune, bool) {
+	switch l.char {
+	case 'n':
+		l.readChar()
+		return '\n', true
+	case 't':
+		l.readChar()
+		return '\t', true
+	case '"':
+		l.readChar()
+		return '"', true
+	case '\\':
+		l.readChar()
+		return '\\', true
+	case 'u':
+		l.readChar()
+		if l.char != '{' {
+			return 0, false
+		}
+		l.readChar()
+
+		pos := l.pos
+		for isHexDigit(l.char) {
+			l.readChar()
+		}
+		digits := l.input[pos:l.pos]
+		if l.char != '}' {
+			return 0, false
+		}
+		l.readChar()
+
+		if len(digits) < 1 || len(digits) > 6 {
+			return 0, false
+		}
+		codePoint, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || !utf8.ValidRune(rune(codePoint)) {
+			return 0, false
+		}
+		return rune(codePoint), true
+	case 0:
+		return 0, false
+	default:
+		l.readChar()
+		return 0, false
+	}
+}
+
 func isIdentInt(ident string) bool {
 	for _, c := range []byte(ident) {
 		if !isDigit(c) {
@@ -203,6 +407,49 @@ func isIdentInt(ident string) bool {
 	return true
 }
 
+// isIdentFloat reports whether ident is a float literal: digits with a
+// fractional part, an exponent, or both, such as 1.5, 1e-3 or 2.5E+10.
+func isIdentFloat(ident string) bool {
+	i := skipDigits(ident, 0)
+	if i == 0 {
+		return false
+	}
+
+	hasFraction := false
+	if i < len(ident) && ident[i] == '.' {
+		end := skipDigits(ident, i+1)
+		if end == i+1 {
+			return false
+		}
+		i = end
+		hasFraction = true
+	}
+
+	hasExponent := false
+	if i < len(ident) && (ident[i] == 'e' || ident[i] == 'E') {
+		i++
+		if i < len(ident) && (ident[i] == '+' || ident[i] == '-') {
+			i++
+		}
+		end := skipDigits(ident, i)
+		if end == i {
+			return false
+		}
+		i = end
+		hasExponent = true
+	}
+
+	return i == len(ident) && (hasFraction || hasExponent)
+}
+
+// skipDigits returns the index of the first non-digit in s at or after i.
+func skipDigits(s string, i int) int {
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return i
+}
+
 func isIdentNil(ident string) bool {
 	return ident == "nil"
 }
@@ -228,6 +475,10 @@ func isLetter(char byte) bool {
 	return ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z')
 }
 
+func isHexDigit(char byte) bool {
+	return isDigit(char) || ('a' <= char && char <= 'f') || ('A' <= char && char <= 'F')
+}
+
 func isDigit(char byte) bool {
 	return '0' <= char && char <= '9'
 }
@@ -3,6 +3,7 @@ package lexer
 import (
 	"testing"
 
+	"github.com/avearmin/simple/internal/diagnostics"
 	"github.com/avearmin/simple/internal/token"
 )
 
@@ -30,6 +31,31 @@ func TestIsIdentInt(t *testing.T) {
 	}
 }
 
+func TestIsIdentFloat(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  bool
+	}{
+		"fraction":                {input: "1.5", want: true},
+		"exponent":                {input: "1e-3", want: true},
+		"fraction and exponent":   {input: "2.5E+10", want: true},
+		"int":                     {input: "15", want: false},
+		"missing fraction digits": {input: "1.", want: false},
+		"missing integer digits":  {input: ".5", want: false},
+		"missing exponent digits": {input: "1e", want: false},
+		"two points":              {input: "1.2.3", want: false},
+		"trailing letters":        {input: "1.5x", want: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := isIdentFloat(test.input); got != test.want {
+				t.Errorf("isIdentFloat(%q) = %v, want %v", test.input, got, test.want)
+			}
+		})
+	}
+}
+
 func TestIsIdentValid(t *testing.T) {
 	tests := map[string]struct {
 		input string
@@ -72,7 +98,7 @@ func TestParse(t *testing.T) {
 (= isBar (>= foo 5))
 (= isBar (< foo 3))
 (= isBar (> foo 3))`,
-			want: []token.Token{
+			want: tokens([]tok{
 				{token.LParen, "(", 1, 0},
 				{token.Assign, ":=", 1, 1},
 				{token.Delimiter, "", 1, 3},
@@ -235,11 +261,11 @@ func TestParse(t *testing.T) {
 				{token.RParen, ")", 12, 17},
 				{token.RParen, ")", 12, 18},
 				{token.EOF, "", 12, 18},
-			},
+			}),
 		},
 		"Illegal token": {
 			input: `(:= foo 5555xxxx)`,
-			want: []token.Token{
+			want: tokens([]tok{
 				{token.LParen, "(", 1, 0},
 				{token.Assign, ":=", 1, 1},
 				{token.Delimiter, "", 1, 3},
@@ -248,14 +274,14 @@ func TestParse(t *testing.T) {
 				{token.Illegal, "5555xxxx", 1, 8},
 				{token.RParen, ")", 1, 16},
 				{token.EOF, "", 1, 16},
-			},
+			}),
 		},
 		"boolean assign with logical operators": {
 			input: `(:= foo false)
 (! foo)
 (&& foo true)
 (|| foo false)`,
-			want: []token.Token{
+			want: tokens([]tok{
 				{token.LParen, "(", 1, 0},
 				{token.Assign, ":=", 1, 1},
 				{token.Delimiter, "", 1, 3},
@@ -286,11 +312,11 @@ func TestParse(t *testing.T) {
 				{token.Bool, "false", 4, 8},
 				{token.RParen, ")", 4, 13},
 				{token.EOF, "", 4, 13},
-			},
+			}),
 		},
 		"control flow keywords": {
 			input: "(if elif else)",
-			want: []token.Token{
+			want: tokens([]tok{
 				{token.LParen, "(", 1, 0},
 				{token.If, "if", 1, 1},
 				{token.Delimiter, "", 1, 3},
@@ -299,7 +325,7 @@ func TestParse(t *testing.T) {
 				{token.Else, "else", 1, 9},
 				{token.RParen, ")", 1, 13},
 				{token.EOF, "", 1, 13},
-			},
+			}),
 		},
 		"multiline if-elif-else": {
 			input: `(if a
@@ -385,6 +411,143 @@ else
 				{Type: token.Nil, Literal: "nil", Line: 1, Col: 8},
 			},
 		},
+		"input ending in an identifier": {
+			input: "(:= foo",
+			want: []token.Token{
+				{Type: token.LParen, Literal: "(", Line: 1, Col: 0},
+				{Type: token.Assign, Literal: ":=", Line: 1, Col: 1},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 3},
+				{Type: token.Ident, Literal: "foo", Line: 1, Col: 4},
+				{Type: token.EOF, Literal: "", Line: 1, Col: 6},
+			},
+		},
+		"float": {
+			input: "(* 1.5 1e-3)",
+			want: []token.Token{
+				{Type: token.LParen, Literal: "(", Line: 1, Col: 0},
+				{Type: token.Multiply, Literal: "*", Line: 1, Col: 1},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 2},
+				{Type: token.Float, Literal: "1.5", Line: 1, Col: 3},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 6},
+				{Type: token.Float, Literal: "1e-3", Line: 1, Col: 7},
+				{Type: token.RParen, Literal: ")", Line: 1, Col: 11},
+			},
+		},
+		"string": {
+			input: `(:= foo "bar (baz)")`,
+			want: []token.Token{
+				{Type: token.LParen, Literal: "(", Line: 1, Col: 0},
+				{Type: token.Assign, Literal: ":=", Line: 1, Col: 1},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 3},
+				{Type: token.Ident, Literal: "foo", Line: 1, Col: 4},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 7},
+				{Type: token.String, Literal: "bar (baz)", Line: 1, Col: 8},
+				{Type: token.RParen, Literal: ")", Line: 1, Col: 19},
+			},
+		},
+		"string with escape sequences": {
+			input: `"a\n\t\"\\\u{48}\u{1F600}"`,
+			want: []token.Token{
+				{Type: token.String, Literal: "a\n\t\"\\H\U0001F600", Line: 1, Col: 0},
+				{Type: token.EOF, Literal: "", Line: 1, Col: 25},
+			},
+		},
+		"string spanning lines": {
+			input: "\"a\nb\" c",
+			want: []token.Token{
+				{Type: token.String, Literal: "a\nb", Line: 1, Col: 0},
+				{Type: token.Delimiter, Literal: "", Line: 2, Col: 2},
+				{Type: token.Ident, Literal: "c", Line: 2, Col: 3},
+			},
+		},
+		"string with invalid escape sequence": {
+			input: `"a\qb" c`,
+			want: []token.Token{
+				{Type: token.Illegal, Literal: `"a\qb"`, Line: 1, Col: 0},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 6},
+			},
+		},
+		"string with invalid code point": {
+			input: `"\u{110000}"`,
+			want: []token.Token{
+				{Type: token.Illegal, Literal: `"\u{110000}"`, Line: 1, Col: 0},
+			},
+		},
+		"line comments": {
+			input: "; header\n(:= foo 1) ; trailing",
+			want: []token.Token{
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 0},
+				{Type: token.LParen, Literal: "(", Line: 2, Col: 0, Comments: []token.Comment{{Text: "; header", Line: 1, Col: 0}}},
+				{Type: token.Assign, Literal: ":=", Line: 2, Col: 1},
+				{Type: token.Delimiter, Literal: "", Line: 2, Col: 3},
+				{Type: token.Ident, Literal: "foo", Line: 2, Col: 4},
+				{Type: token.Delimiter, Literal: "", Line: 2, Col: 7},
+				{Type: token.Int, Literal: "1", Line: 2, Col: 8},
+				{Type: token.RParen, Literal: ")", Line: 2, Col: 9},
+				{Type: token.Delimiter, Literal: "", Line: 2, Col: 10},
+				{Type: token.EOF, Literal: "", Line: 2, Col: 20, Comments: []token.Comment{{Text: "; trailing", Line: 2, Col: 11}}},
+			},
+		},
+		"comment ending an identifier": {
+			input: "foo;bar",
+			want: []token.Token{
+				{Type: token.Ident, Literal: "foo", Line: 1, Col: 0},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 3},
+				{Type: token.EOF, Literal: "", Line: 1, Col: 6, Comments: []token.Comment{{Text: ";bar", Line: 1, Col: 3}}},
+			},
+		},
+		"nested block comments": {
+			input: "(:= #| a #| b |# c |# foo 1)",
+			want: []token.Token{
+				{Type: token.LParen, Literal: "(", Line: 1, Col: 0},
+				{Type: token.Assign, Literal: ":=", Line: 1, Col: 1},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 3},
+				{Type: token.Ident, Literal: "foo", Line: 1, Col: 22, Comments: []token.Comment{{Text: "#| a #| b |# c |#", Line: 1, Col: 4}}},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 25},
+				{Type: token.Int, Literal: "1", Line: 1, Col: 26},
+				{Type: token.RParen, Literal: ")", Line: 1, Col: 27},
+				{Type: token.EOF, Literal: "", Line: 1, Col: 27},
+			},
+		},
+		"comments before a closing parenthesis": {
+			input: "(f x ; c\n)(g #| d |#)",
+			want: []token.Token{
+				{Type: token.LParen, Literal: "(", Line: 1, Col: 0},
+				{Type: token.Ident, Literal: "f", Line: 1, Col: 1},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 2},
+				{Type: token.Ident, Literal: "x", Line: 1, Col: 3},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 4},
+				{Type: token.RParen, Literal: ")", Line: 2, Col: 0, Comments: []token.Comment{{Text: "; c", Line: 1, Col: 5}}},
+				{Type: token.LParen, Literal: "(", Line: 2, Col: 1},
+				{Type: token.Ident, Literal: "g", Line: 2, Col: 2},
+				{Type: token.Delimiter, Literal: "", Line: 2, Col: 3},
+				{Type: token.RParen, Literal: ")", Line: 2, Col: 11, Comments: []token.Comment{{Text: "#| d |#", Line: 2, Col: 4}}},
+				{Type: token.EOF, Literal: "", Line: 2, Col: 11},
+			},
+		},
+		"unterminated block comment": {
+			input: "(:= foo #| bar",
+			want: []token.Token{
+				{Type: token.LParen, Literal: "(", Line: 1, Col: 0},
+				{Type: token.Assign, Literal: ":=", Line: 1, Col: 1},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 3},
+				{Type: token.Ident, Literal: "foo", Line: 1, Col: 4},
+				{Type: token.Illegal, Literal: "#| bar", Line: 1, Col: 8},
+				{Type: token.EOF, Literal: "", Line: 1, Col: 13},
+			},
+		},
+		"unterminated string": {
+			input: `(:= foo "bar`,
+			want: []token.Token{
+				{Type: token.LParen, Literal: "(", Line: 1, Col: 0},
+				{Type: token.Assign, Literal: ":=", Line: 1, Col: 1},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 3},
+				{Type: token.Ident, Literal: "foo", Line: 1, Col: 4},
+				{Type: token.Delimiter, Literal: "", Line: 1, Col: 7},
+				{Type: token.Illegal, Literal: `"bar`, Line: 1, Col: 8},
+				{Type: token.EOF, Literal: "", Line: 1, Col: 11},
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -401,6 +564,150 @@ else
 	}
 }
 
+// tok is a token without comments, which the fixtures that check only the
+// type, literal and position of each token are written in.
+type tok struct {
+	Type    token.Type
+	Literal string
+	Line    int
+	Col     int
+}
+
+func tokens(toks []tok) []token.Token {
+	converted := make([]token.Token, len(toks))
+	for i, t := range toks {
+		converted[i] = token.Token{Type: t.Type, Literal: t.Literal, Line: t.Line, Col: t.Col}
+	}
+	return converted
+}
+
 func isEqualTokens(tokenOne, tokenTwo token.Token) bool {
+	if len(tokenOne.Comments) != len(tokenTwo.Comments) {
+		return false
+	}
+	for i := range tokenOne.Comments {
+		if tokenOne.Comments[i] != tokenTwo.Comments[i] {
+			return false
+		}
+	}
+
 	return (tokenOne.Type == tokenTwo.Type) && (tokenOne.Literal == tokenTwo.Literal) && (tokenOne.Line == tokenTwo.Line) && (tokenOne.Col == tokenTwo.Col)
 }
+
+func TestTokenSpans(t *testing.T) {
+	type span struct{ start, end token.Position }
+	pos := func(offset, line, col int) token.Position {
+		return token.Position{Offset: offset, Line: line, Col: col}
+	}
+
+	tests := map[string]struct {
+		input string
+		want  []span
+	}{
+		"one line": {
+			input: "(:= x 10)",
+			want: []span{
+				{pos(0, 1, 0), pos(1, 1, 1)},
+				{pos(1, 1, 1), pos(3, 1, 3)},
+				{pos(3, 1, 3), pos(4, 1, 4)},
+				{pos(4, 1, 4), pos(5, 1, 5)},
+				{pos(5, 1, 5), pos(6, 1, 6)},
+				{pos(6, 1, 6), pos(8, 1, 8)},
+				{pos(8, 1, 8), pos(9, 1, 9)},
+			},
+		},
+		"string across lines": {
+			input: "(print \"a\nbc\")\n",
+			want: []span{
+				{pos(0, 1, 0), pos(1, 1, 1)},
+				{pos(1, 1, 1), pos(6, 1, 6)},
+				{pos(6, 1, 6), pos(7, 1, 7)},
+				{pos(7, 1, 7), pos(13, 2, 3)},
+				{pos(13, 2, 3), pos(14, 2, 4)},
+				{pos(14, 2, 4), pos(15, 3, 0)},
+			},
+		},
+		"delimiter with a comment": {
+			input: "x ; note\n  y",
+			want: []span{
+				{pos(0, 1, 0), pos(1, 1, 1)},
+				{pos(1, 1, 1), pos(11, 2, 2)},
+				{pos(11, 2, 2), pos(12, 2, 3)},
+			},
+		},
+		"unterminated block comment": {
+			input: "x  #| y",
+			want: []span{
+				{pos(0, 1, 0), pos(1, 1, 1)},
+				{pos(3, 1, 3), pos(7, 1, 7)},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := New(test.input)
+
+			for i, want := range test.want {
+				tok := l.NextToken()
+				if got := (span{tok.Pos(), tok.End()}); got != want {
+					t.Fatalf("%d: %q: got=%+v, want=%+v", i, tok.Literal, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	tests := map[string]struct {
+		input       string
+		wantCode    diagnostics.Code
+		wantMessage string
+		wantFix     string
+	}{
+		"unterminated string": {
+			input:       `"abc`,
+			wantCode:    diagnostics.UnterminatedString,
+			wantMessage: "string is not terminated",
+			wantFix:     `"`,
+		},
+		"invalid escape": {
+			input:       `"a\qb"`,
+			wantCode:    diagnostics.InvalidEscape,
+			wantMessage: `invalid escape sequence '\q'`,
+		},
+		"unterminated block comment": {
+			input:       "#| a #| b |#",
+			wantCode:    diagnostics.UnterminatedComment,
+			wantMessage: "block comment is not terminated",
+			wantFix:     "|#",
+		},
+		"invalid number": {
+			input:       "1.2.3",
+			wantCode:    diagnostics.InvalidLiteral,
+			wantMessage: "invalid number '1.2.3'",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tok := New(test.input).NextToken()
+			if tok.Type != token.Illegal {
+				t.Fatalf("expected an illegal token, but got %+v", tok)
+			}
+
+			d := Diagnose(tok)
+			if d.Code != test.wantCode || d.Message != test.wantMessage {
+				t.Fatalf("got=%s %q, want=%s %q", d.Code, d.Message, test.wantCode, test.wantMessage)
+			}
+
+			var fix string
+			if d.Fix != nil {
+				fix = d.Fix.Replacement
+			}
+			if fix != test.wantFix {
+				t.Fatalf("expected the fix to insert %q, but got %q", test.wantFix, fix)
+			}
+		})
+	}
+}
@@ -0,0 +1,451 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Builtins are shared by the evaluator and the compiler, which refers to each
+// builtin by its index in this list.
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"print",
+		&Builtin{Name: "print", Fn: func(out io.Writer, args ...Object) Object {
+			values := make([]string, 0, len(args))
+			for _, arg := range args {
+				values = append(values, arg.Inspect())
+			}
+			fmt.Fprintln(out, strings.Join(values, " "))
+			return Nil{}
+		}},
+	},
+	{
+		"int",
+		&Builtin{Name: "int", Fn: func(out io.Writer, args ...Object) Object {
+			if err := checkArgumentCount("int", 1, args); err != nil {
+				return err
+			}
+
+			switch arg := args[0].(type) {
+			case Integer:
+				return arg
+			case Float:
+				if math.IsNaN(arg.Value) || arg.Value < math.MinInt64 || arg.Value >= math.MaxInt64 {
+					return conversionError(arg, IntegerObj)
+				}
+				return Integer{Value: int64(arg.Value)}
+			case String:
+				value, err := strconv.ParseInt(arg.Value, 10, 64)
+				if err != nil {
+					return conversionError(arg, IntegerObj)
+				}
+				return Integer{Value: value}
+			default:
+				return conversionError(arg, IntegerObj)
+			}
+		}},
+	},
+	{
+		"float",
+		&Builtin{Name: "float", Fn: func(out io.Writer, args ...Object) Object {
+			if err := checkArgumentCount("float", 1, args); err != nil {
+				return err
+			}
+
+			switch arg := args[0].(type) {
+			case Integer:
+				return Float{Value: float64(arg.Value)}
+			case Float:
+				return arg
+			case String:
+				value, err := strconv.ParseFloat(arg.Value, 64)
+				if err != nil {
+					return conversionError(arg, FloatObj)
+				}
+				return Float{Value: value}
+			default:
+				return conversionError(arg, FloatObj)
+			}
+		}},
+	},
+	{"len", &Builtin{Name: "len", Fn: builtinLen}},
+	{"get", &Builtin{Name: "get", Fn: builtinGet}},
+	{"set", &Builtin{Name: "set", Fn: builtinSet}},
+	{"push", &Builtin{Name: "push", Fn: builtinPush}},
+	{"pop", &Builtin{Name: "pop", Fn: builtinPop}},
+	{"slice", &Builtin{Name: "slice", Fn: builtinSlice}},
+	{"concat", &Builtin{Name: "concat", Fn: builtinConcat}},
+	{"map", &Builtin{Name: "map", Fn: builtinMap}},
+	{"put", &Builtin{Name: "put", Fn: builtinPut}},
+	{"delete", &Builtin{Name: "delete", Fn: builtinDelete}},
+	{"has", &Builtin{Name: "has", Fn: builtinHas}},
+	{"keys", &Builtin{Name: "keys", Fn: builtinKeys}},
+	{"values", &Builtin{Name: "values", Fn: builtinValues}},
+	{"range", &Builtin{Name: "range", Fn: builtinRange}},
+}
+
+func GetBuiltinByName(name string) (*Builtin, bool) {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin, true
+		}
+	}
+	return nil, false
+}
+
+func builtinLen(out io.Writer, args ...Object) Object {
+	if err := checkArgumentCount("len", 1, args); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *List:
+		return Integer{Value: int64(len(arg.Elements))}
+	case *Map:
+		return Integer{Value: int64(arg.Len())}
+	case String:
+		return Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	default:
+		return &Error{
+			Kind: TypeMismatchError,
+			Message: fmt.Sprintf("expected argument 1 of 'len' to be '%s', '%s' or '%s', but got '%s'",
+				ListObj, MapObj, StringObj, arg.Type()),
+		}
+	}
+}
+
+// builtinGet returns the element of a list at an index, or the value of a
+// key in a map.
+func builtinGet(out io.Writer, args ...Object) Object {
+	if err := checkArgumentCount("get", 2, args); err != nil {
+		return err
+	}
+
+	if m, ok := args[0].(*Map); ok {
+		key, err := mapKey("get", args[1])
+		if err != nil {
+			return err
+		}
+		value, ok := m.Get(key)
+		if !ok {
+			return &Error{Kind: KeyNotFoundError, Message: fmt.Sprintf("key %s not found in map", inspectElement(key))}
+		}
+		return value
+	}
+
+	list, index, err := listAndIndex("get", args)
+	if err != nil {
+		return err
+	}
+
+	return list.Elements[index]
+}
+
+// builtinSet replaces the element of a list at an index, and returns the
+// list.
+func builtinSet(out io.Writer, args ...Object) Object {
+	if err := checkArgumentCount("set", 3, args); err != nil {
+		return err
+	}
+	list, index, err := listAndIndex("set", args)
+	if err != nil {
+		return err
+	}
+
+	list.Elements[index] = args[2]
+	return list
+}
+
+// builtinPush appends values to the end of a list, and returns the list.
+func builtinPush(out io.Writer, args ...Object) Object {
+	if len(args) < 2 {
+		return &Error{
+			Kind:    ArgumentCountError,
+			Message: fmt.Sprintf("function 'push' expects at least 2 arguments, but got %d", len(args)),
+		}
+	}
+	list, ok := args[0].(*List)
+	if !ok {
+		return argumentTypeError("push", 1, ListObj, args[0])
+	}
+
+	list.Elements = append(list.Elements, args[1:]...)
+	return list
+}
+
+// builtinPop removes the last element of a list and returns it.
+func builtinPop(out io.Writer, args ...Object) Object {
+	if err := checkArgumentCount("pop", 1, args); err != nil {
+		return err
+	}
+	list, ok := args[0].(*List)
+	if !ok {
+		return argumentTypeError("pop", 1, ListObj, args[0])
+	}
+
+	if len(list.Elements) == 0 {
+		return &Error{Kind: IndexOutOfBoundsError, Message: "cannot pop from an empty list"}
+	}
+
+	last := list.Elements[len(list.Elements)-1]
+	list.Elements = list.Elements[:len(list.Elements)-1]
+	return last
+}
+
+// builtinSlice returns a new list of the elements from a start index up to,
+// but not including, an end index.
+func builtinSlice(out io.Writer, args ...Object) Object {
+	if err := checkArgumentCount("slice", 3, args); err != nil {
+		return err
+	}
+	list, ok := args[0].(*List)
+	if !ok {
+		return argumentTypeError("slice", 1, ListObj, args[0])
+	}
+	start, ok := args[1].(Integer)
+	if !ok {
+		return argumentTypeError("slice", 2, IntegerObj, args[1])
+	}
+	end, ok := args[2].(Integer)
+	if !ok {
+		return argumentTypeError("slice", 3, IntegerObj, args[2])
+	}
+
+	if start.Value < 0 || start.Value > end.Value || end.Value > int64(len(list.Elements)) {
+		return &Error{
+			Kind: IndexOutOfBoundsError,
+			Message: fmt.Sprintf("slice bounds %d to %d out of range for list of length %d",
+				start.Value, end.Value, len(list.Elements)),
+		}
+	}
+
+	elements := make([]Object, end.Value-start.Value)
+	copy(elements, list.Elements[start.Value:end.Value])
+	return &List{Elements: elements}
+}
+
+// builtinConcat returns a new list of the elements of every list it is given,
+// in order.
+func builtinConcat(out io.Writer, args ...Object) Object {
+	elements := []Object{}
+	for i, arg := range args {
+		list, ok := arg.(*List)
+		if !ok {
+			return argumentTypeError("concat", i+1, ListObj, arg)
+		}
+		elements = append(elements, list.Elements...)
+	}
+	return &List{Elements: elements}
+}
+
+// builtinMap returns a new map of its arguments, which alternate between
+// keys and values.
+func builtinMap(out io.Writer, args ...Object) Object {
+	if len(args)%2 != 0 {
+		return &Error{
+			Kind:    ArgumentCountError,
+			Message: fmt.Sprintf("function 'map' expects an even number of arguments, but got %d", len(args)),
+		}
+	}
+
+	m := NewMap()
+	for i := 0; i < len(args); i += 2 {
+		key, err := mapKey("map", args[i])
+		if err != nil {
+			return err
+		}
+		m.Put(key, args[i+1])
+	}
+	return m
+}
+
+// builtinPut binds a key of a map to a value, and returns the map.
+func builtinPut(out io.Writer, args ...Object) Object {
+	if err := checkArgumentCount("put", 3, args); err != nil {
+		return err
+	}
+	m, ok := args[0].(*Map)
+	if !ok {
+		return argumentTypeError("put", 1, MapObj, args[0])
+	}
+	key, err := mapKey("put", args[1])
+	if err != nil {
+		return err
+	}
+
+	m.Put(key, args[2])
+	return m
+}
+
+// builtinDelete removes a key from a map, if it is there, and returns the
+// map.
+func builtinDelete(out io.Writer, args ...Object) Object {
+	if err := checkArgumentCount("delete", 2, args); err != nil {
+		return err
+	}
+	m, ok := args[0].(*Map)
+	if !ok {
+		return argumentTypeError("delete", 1, MapObj, args[0])
+	}
+	key, err := mapKey("delete", args[1])
+	if err != nil {
+		return err
+	}
+
+	m.Delete(key)
+	return m
+}
+
+func builtinHas(out io.Writer, args ...Object) Object {
+	if err := checkArgumentCount("has", 2, args); err != nil {
+		return err
+	}
+	m, ok := args[0].(*Map)
+	if !ok {
+		return argumentTypeError("has", 1, MapObj, args[0])
+	}
+	key, err := mapKey("has", args[1])
+	if err != nil {
+		return err
+	}
+
+	_, found := m.Get(key)
+	return Boolean{Value: found}
+}
+
+// builtinKeys returns a new list of the keys of a map, in insertion order.
+func builtinKeys(out io.Writer, args ...Object) Object {
+	if err := checkArgumentCount("keys", 1, args); err != nil {
+		return err
+	}
+	m, ok := args[0].(*Map)
+	if !ok {
+		return argumentTypeError("keys", 1, MapObj, args[0])
+	}
+
+	keys := make([]Object, 0, m.Len())
+	for _, pair := range m.Pairs() {
+		keys = append(keys, pair.Key)
+	}
+	return &List{Elements: keys}
+}
+
+// builtinValues returns a new list of the values of a map, in the insertion
+// order of their keys.
+func builtinValues(out io.Writer, args ...Object) Object {
+	if err := checkArgumentCount("values", 1, args); err != nil {
+		return err
+	}
+	m, ok := args[0].(*Map)
+	if !ok {
+		return argumentTypeError("values", 1, MapObj, args[0])
+	}
+
+	values := make([]Object, 0, m.Len())
+	for _, pair := range m.Pairs() {
+		values = append(values, pair.Value)
+	}
+	return &List{Elements: values}
+}
+
+// builtinRange returns a new list of the integers from a start, which is 0
+// if it is not given, up to but not including a stop, counting by a step,
+// which is 1 if it is not given.
+func builtinRange(out io.Writer, args ...Object) Object {
+	if len(args) < 1 || len(args) > 3 {
+		return &Error{
+			Kind:    ArgumentCountError,
+			Message: fmt.Sprintf("function 'range' expects 1 to 3 arguments, but got %d", len(args)),
+		}
+	}
+
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		integer, ok := arg.(Integer)
+		if !ok {
+			return argumentTypeError("range", i+1, IntegerObj, arg)
+		}
+		bounds[i] = integer.Value
+	}
+
+	start, stop, step := int64(0), bounds[0], int64(1)
+	if len(bounds) > 1 {
+		start, stop = bounds[0], bounds[1]
+	}
+	if len(bounds) > 2 {
+		step = bounds[2]
+	}
+	if step == 0 {
+		return &Error{Kind: BuiltinError, Message: "'range' step cannot be zero"}
+	}
+
+	elements := []Object{}
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		elements = append(elements, Integer{Value: i})
+	}
+	return &List{Elements: elements}
+}
+
+func mapKey(name string, arg Object) (Hashable, *Error) {
+	key, ok := arg.(Hashable)
+	if !ok {
+		return nil, &Error{
+			Kind:    TypeMismatchError,
+			Message: fmt.Sprintf("'%s' cannot use a value of type '%s' as a map key", name, arg.Type()),
+		}
+	}
+	return key, nil
+}
+
+// listAndIndex checks that the first two of args are a list and an index
+// within its bounds.
+func listAndIndex(name string, args []Object) (*List, int64, *Error) {
+	list, ok := args[0].(*List)
+	if !ok {
+		return nil, 0, argumentTypeError(name, 1, ListObj, args[0])
+	}
+	index, ok := args[1].(Integer)
+	if !ok {
+		return nil, 0, argumentTypeError(name, 2, IntegerObj, args[1])
+	}
+
+	if index.Value < 0 || index.Value >= int64(len(list.Elements)) {
+		return nil, 0, &Error{
+			Kind:    IndexOutOfBoundsError,
+			Message: fmt.Sprintf("index %d out of range for list of length %d", index.Value, len(list.Elements)),
+		}
+	}
+
+	return list, index.Value, nil
+}
+
+func checkArgumentCount(name string, want int, args []Object) *Error {
+	if len(args) != want {
+		return &Error{
+			Kind:    ArgumentCountError,
+			Message: fmt.Sprintf("function '%s' expects %d arguments, but got %d", name, want, len(args)),
+		}
+	}
+	return nil
+}
+
+func conversionError(arg Object, to Type) *Error {
+	return &Error{
+		Kind:    BuiltinError,
+		Message: fmt.Sprintf("cannot convert %s '%s' to %s", arg.Type(), arg.Inspect(), to),
+	}
+}
+
+func argumentTypeError(name string, position int, want Type, arg Object) *Error {
+	return &Error{
+		Kind:    TypeMismatchError,
+		Message: fmt.Sprintf("expected argument %d of '%s' to be '%s', but got '%s'", position, name, want, arg.Type()),
+	}
+}
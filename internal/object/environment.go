@@ -0,0 +1,89 @@
+package object
+
+import (
+	"io"
+	"sort"
+)
+
+// MaxCallDepth is the number of calls that can be in progress at once,
+// counting the program itself, before a call overflows the stack.
+const MaxCallDepth = 1024
+
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+	depth int // the number of calls in progress in this scope
+	out   io.Writer
+}
+
+// NewEnvironment creates the outermost scope of a program, whose output is
+// written to out.
+func NewEnvironment(out io.Writer) *Environment {
+	return &Environment{store: map[string]Object{}, out: out}
+}
+
+// NewEnclosedEnvironment creates a scope nested inside outer. Names that are
+// not found in the new scope are looked up in outer.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment(outer.out)
+	env.outer = outer
+	env.depth = outer.depth
+	return env
+}
+
+// NewCallEnvironment creates the scope of a call, made from the scope caller,
+// to a function defined in outer. It is one call deeper than caller.
+func NewCallEnvironment(outer, caller *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.depth = caller.depth + 1
+	return env
+}
+
+// Depth returns the number of calls in progress in this scope.
+func (e *Environment) Depth() int {
+	return e.depth
+}
+
+// Output returns the writer the output of the program is written to.
+func (e *Environment) Output() io.Writer {
+	return e.out
+}
+
+// Get looks name up in this scope, and then in each enclosing scope.
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set binds name in this scope, shadowing any binding in an enclosing scope.
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Reassign replaces the value of name in the nearest scope that defines it.
+// It reports false if no scope defines name.
+func (e *Environment) Reassign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Reassign(name, val)
+	}
+	return false
+}
+
+// Names returns the names bound in this scope, in sorted order. Names bound
+// only in an enclosing scope are not included.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,79 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/avearmin/simple/internal/token"
+)
+
+type ErrorKind string
+
+const (
+	DivisionByZeroError       = "DIVISION_BY_ZERO"
+	ModuloByZeroError         = "MODULO_BY_ZERO"
+	UndefinedIdentifierError  = "UNDEFINED_IDENTIFIER"
+	ReassignBeforeAssignError = "REASSIGN_BEFORE_ASSIGN"
+	TypeMismatchError         = "TYPE_MISMATCH"
+	ArgumentCountError        = "ARGUMENT_COUNT"
+	NotCallableError          = "NOT_CALLABLE"
+	InvalidLiteralError       = "INVALID_LITERAL"
+	BuiltinError              = "BUILTIN"
+	UnsupportedError          = "UNSUPPORTED"
+	StackOverflowError        = "STACK_OVERFLOW"
+	IndexOutOfBoundsError     = "INDEX_OUT_OF_BOUNDS"
+	KeyNotFoundError          = "KEY_NOT_FOUND"
+)
+
+// Frame is a single FnCall site in the call stack of an Error.
+type Frame struct {
+	Function string
+	Line     int
+	Col      int
+}
+
+// Error is a runtime error. Line and Col are the position of the token that
+// caused it, and Trace holds the calls it unwound through, innermost first.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Line    int
+	Col     int
+	Trace   []Frame
+}
+
+// NewError creates an Error reported at the position of tok.
+func NewError(kind ErrorKind, tok token.Token, format string, a ...any) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, a...), Line: tok.Line, Col: tok.Col}
+}
+
+// Inspect prints the error and its trace. A run of identical frames, such
+// as the ones left by a recursive call that overflowed the stack, is printed
+// once followed by the number of calls it repeats.
+func (e *Error) Inspect() string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("%s on line %d col %d", e.Message, e.Line, e.Col))
+	for i := 0; i < len(e.Trace); {
+		frame := e.Trace[i]
+		builder.WriteString(fmt.Sprintf("\n\tin %s called on line %d col %d", frame.Function, frame.Line, frame.Col))
+
+		repeats := 0
+		for i++; i < len(e.Trace) && e.Trace[i] == frame; i++ {
+			repeats++
+		}
+		if repeats > 0 {
+			builder.WriteString(fmt.Sprintf("\n\t... %d more calls", repeats))
+		}
+	}
+
+	return builder.String()
+}
+func (e *Error) Type() Type { return ErrorObj }
+
+// RuntimeError wraps an Error so it can be returned as a Go error.
+type RuntimeError struct {
+	Object *Error
+}
+
+func (re *RuntimeError) Error() string { return re.Object.Inspect() }
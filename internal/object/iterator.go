@@ -0,0 +1,52 @@
+package object
+
+// Iterator steps through the items of a list or map as they were when it was
+// created, so changes made to the collection during a loop do not affect it.
+// An item of a list is its index and element, and an item of a map is a key
+// and its value.
+type Iterator struct {
+	items []MapPair
+	count int
+	keyed bool
+	next  int
+}
+
+// NewIterator returns an Iterator over obj that produces count values for
+// each item: the element of a list or the key of a map when count is 1, and
+// the whole item when it is 2. It reports false if obj cannot be iterated.
+func NewIterator(obj Object, count int) (*Iterator, bool) {
+	switch obj := obj.(type) {
+	case *List:
+		items := make([]MapPair, len(obj.Elements))
+		for i, element := range obj.Elements {
+			items[i] = MapPair{Key: Integer{Value: int64(i)}, Value: element}
+		}
+		return &Iterator{items: items, count: count}, true
+	case *Map:
+		return &Iterator{items: obj.Pairs(), count: count, keyed: true}, true
+	default:
+		return nil, false
+	}
+}
+
+func (it *Iterator) Inspect() string { return "iterator" }
+func (it *Iterator) Type() Type      { return IteratorObj }
+
+// Next returns the values of the next item, or false once every item has
+// been returned.
+func (it *Iterator) Next() ([]Object, bool) {
+	if it.next >= len(it.items) {
+		return nil, false
+	}
+	item := it.items[it.next]
+	it.next++
+
+	switch {
+	case it.count == 2:
+		return []Object{item.Key, item.Value}, true
+	case it.keyed:
+		return []Object{item.Key}, true
+	default:
+		return []Object{item.Value}, true
+	}
+}
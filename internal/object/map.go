@@ -0,0 +1,103 @@
+package object
+
+import "strings"
+
+// HashKey identifies the value of a Hashable object. Two objects have the
+// same HashKey exactly when they are equal.
+type HashKey struct {
+	Type Type
+	Int  int64
+	Str  string
+}
+
+// Hashable is implemented by the objects that can be used as map keys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i Integer) HashKey() HashKey { return HashKey{Type: IntegerObj, Int: i.Value} }
+
+func (b Boolean) HashKey() HashKey {
+	if b.Value {
+		return HashKey{Type: BooleanObj, Int: 1}
+	}
+	return HashKey{Type: BooleanObj}
+}
+
+func (s String) HashKey() HashKey { return HashKey{Type: StringObj, Str: s.Value} }
+
+type MapPair struct {
+	Key   Object
+	Value Object
+}
+
+// Map is a mutable collection of key value pairs. Its pairs are kept in the
+// order their keys were first put into it. Like a List, copies of a Map share
+// its pairs.
+type Map struct {
+	pairs map[HashKey]*MapPair
+	order []HashKey
+}
+
+func NewMap() *Map {
+	return &Map{pairs: map[HashKey]*MapPair{}}
+}
+
+func (m *Map) Inspect() string {
+	pairs := make([]string, 0, len(m.order))
+	for _, pair := range m.Pairs() {
+		pairs = append(pairs, inspectElement(pair.Key)+": "+inspectElement(pair.Value))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+func (m *Map) Type() Type { return MapObj }
+
+func (m *Map) Len() int { return len(m.order) }
+
+func (m *Map) Get(key Hashable) (Object, bool) {
+	pair, ok := m.pairs[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+// Put binds key to value. A key that is already in the map keeps its place
+// in the order.
+func (m *Map) Put(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if pair, ok := m.pairs[hashKey]; ok {
+		pair.Value = value
+		return
+	}
+
+	m.pairs[hashKey] = &MapPair{Key: key, Value: value}
+	m.order = append(m.order, hashKey)
+}
+
+// Delete removes key from the map. It reports false if key was not in it.
+func (m *Map) Delete(key Hashable) bool {
+	hashKey := key.HashKey()
+	if _, ok := m.pairs[hashKey]; !ok {
+		return false
+	}
+
+	delete(m.pairs, hashKey)
+	for i, k := range m.order {
+		if k == hashKey {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Pairs returns the pairs of the map in insertion order.
+func (m *Map) Pairs() []MapPair {
+	pairs := make([]MapPair, 0, len(m.order))
+	for _, hashKey := range m.order {
+		pairs = append(pairs, *m.pairs[hashKey])
+	}
+	return pairs
+}
@@ -1,13 +1,34 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/code"
+)
 
 type Type string
 
 const (
 	IntegerObj = "INTEGER"
+	FloatObj   = "FLOAT"
 	BooleanObj = "BOOLEAN"
 	NilObj     = "NIL"
+	StringObj  = "STRING"
+	ListObj    = "LIST"
+	MapObj     = "MAP"
+
+	ReturnValueObj = "RETURN_VALUE"
+	FunctionObj    = "FUNCTION"
+	BuiltinObj     = "BUILTIN"
+	ErrorObj       = "ERROR"
+
+	IteratorObj         = "ITERATOR"
+	CompiledFunctionObj = "COMPILED_FUNCTION"
+	CellObj             = "CELL"
 )
 
 type Object interface {
@@ -22,13 +43,136 @@ type Integer struct {
 func (i Integer) Inspect() string { return fmt.Sprintf("%d", i.Value) }
 func (i Integer) Type() Type      { return IntegerObj }
 
+type Float struct {
+	Value float64
+}
+
+// Inspect always includes a decimal point or an exponent, so a float can be
+// told apart from an integer.
+func (f Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+	return s + ".0"
+}
+func (f Float) Type() Type { return FloatObj }
+
 type Boolean struct {
 	Value bool
 }
 
 func (b Boolean) Inspect() string { return fmt.Sprintf("%t", b.Value) }
+func (b Boolean) Type() Type      { return BooleanObj }
+
+type String struct {
+	Value string
+}
+
+func (s String) Inspect() string { return s.Value }
+func (s String) Type() Type      { return StringObj }
+
+// List is a mutable sequence of values. Copies of a List share its elements,
+// so changes made through one are seen through every other.
+type List struct {
+	Elements []Object
+}
+
+func (l *List) Inspect() string {
+	elements := make([]string, 0, len(l.Elements))
+	for _, element := range l.Elements {
+		elements = append(elements, inspectElement(element))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+func (l *List) Type() Type { return ListObj }
+
+// inspectElement is the Inspect of an object inside a list or map, where
+// strings are quoted.
+func inspectElement(obj Object) string {
+	if str, ok := obj.(String); ok {
+		return strconv.Quote(str.Value)
+	}
+	return obj.Inspect()
+}
 
 type Nil struct{}
 
 func (n Nil) Inspect() string { return "nil" }
 func (n Nil) Type() Type      { return NilObj }
+
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv ReturnValue) Inspect() string { return rv.Value.Inspect() }
+func (rv ReturnValue) Type() Type      { return ReturnValueObj }
+
+// AnonymousFunction is the name of every function created by a function
+// literal.
+const AnonymousFunction = "<anonymous>"
+
+type Function struct {
+	Name       string
+	Parameters []ast.Atom
+	Statements []ast.Statement
+	Env        *Environment
+}
+
+func (f *Function) Inspect() string {
+	params := make([]string, 0, len(f.Parameters))
+	for _, param := range f.Parameters {
+		params = append(params, param.Value)
+	}
+	return fmt.Sprintf("fn %s(%s)", f.Name, strings.Join(params, ", "))
+}
+func (f *Function) Type() Type { return FunctionObj }
+
+// BuiltinFunction reports failures by returning an *Error. The position of
+// the error is filled in by the caller. Builtins that print write to out.
+type BuiltinFunction func(out io.Writer, args ...Object) Object
+
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Inspect() string { return fmt.Sprintf("builtin %s", b.Name) }
+func (b *Builtin) Type() Type      { return BuiltinObj }
+
+type CompiledFunction struct {
+	Name         string
+	Parameters   []string
+	Instructions code.Instructions
+	NumLocals    int
+	SourceMap    code.SourceMap
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("fn %s(%s)", cf.Name, strings.Join(cf.Parameters, ", "))
+}
+func (cf *CompiledFunction) Type() Type { return CompiledFunctionObj }
+
+// Closure is a CompiledFunction together with the cells of the variables it
+// captured from the functions enclosing it.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+}
+
+func (c *Closure) Inspect() string { return c.Fn.Inspect() }
+func (c *Closure) Type() Type      { return FunctionObj }
+
+// Cell holds the value of a local variable in the virtual machine, so that
+// closures share the variable rather than a copy of its value.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Inspect() string {
+	if c.Value == nil {
+		return "cell()"
+	}
+	return fmt.Sprintf("cell(%s)", c.Value.Inspect())
+}
+func (c *Cell) Type() Type { return CellObj }
@@ -0,0 +1,152 @@
+package object
+
+import (
+	"math"
+
+	"github.com/avearmin/simple/internal/token"
+)
+
+// BinaryOperation applies the operator op to left and right. Failures are
+// returned as an *Error, whose position is filled in by the caller.
+//
+// An operation between an INTEGER and a FLOAT promotes the INTEGER to a FLOAT
+// first, including for equality.
+func BinaryOperation(op token.Type, left, right Object) Object {
+	if leftFloat, rightFloat, ok := promoteToFloats(left, right); ok {
+		return floatBinaryOperation(op, leftFloat, rightFloat)
+	}
+
+	switch op {
+	case token.Equals:
+		return Boolean{Value: left == right}
+	case token.NotEquals:
+		return Boolean{Value: left != right}
+	}
+
+	if left.Type() != right.Type() {
+		return typeMismatchError(op, left, right)
+	}
+
+	switch left := left.(type) {
+	case Integer:
+		return integerBinaryOperation(op, left.Value, right.(Integer).Value)
+	case String:
+		return stringBinaryOperation(op, left.Value, right.(String).Value)
+	default:
+		return typeMismatchError(op, left, right)
+	}
+}
+
+// promoteToFloats converts left and right to floats if they are numbers and
+// at least one of them is a FLOAT.
+func promoteToFloats(left, right Object) (float64, float64, bool) {
+	_, leftIsFloat := left.(Float)
+	_, rightIsFloat := right.(Float)
+	if !leftIsFloat && !rightIsFloat {
+		return 0, 0, false
+	}
+
+	leftFloat, ok := toFloat(left)
+	if !ok {
+		return 0, 0, false
+	}
+	rightFloat, ok := toFloat(right)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return leftFloat, rightFloat, true
+}
+
+func toFloat(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case Integer:
+		return float64(obj.Value), true
+	case Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
+func integerBinaryOperation(op token.Type, left, right int64) Object {
+	switch op {
+	case token.Add:
+		return Integer{Value: left + right}
+	case token.Subtract:
+		return Integer{Value: left - right}
+	case token.Multiply:
+		return Integer{Value: left * right}
+	case token.Divide:
+		if right == 0 {
+			return &Error{Kind: DivisionByZeroError, Message: "division by zero"}
+		}
+		return Integer{Value: left / right}
+	case token.Modulo:
+		if right == 0 {
+			return &Error{Kind: ModuloByZeroError, Message: "modulo by zero"}
+		}
+		return Integer{Value: left % right}
+	case token.LessThan:
+		return Boolean{Value: left < right}
+	case token.GreaterThan:
+		return Boolean{Value: left > right}
+	case token.LessThanOrEquals:
+		return Boolean{Value: left <= right}
+	case token.GreaterThanOrEquals:
+		return Boolean{Value: left >= right}
+	default:
+		return &Error{Kind: UnsupportedError, Message: "unknown operator '" + string(op) + "'"}
+	}
+}
+
+func floatBinaryOperation(op token.Type, left, right float64) Object {
+	switch op {
+	case token.Add:
+		return Float{Value: left + right}
+	case token.Subtract:
+		return Float{Value: left - right}
+	case token.Multiply:
+		return Float{Value: left * right}
+	case token.Divide:
+		if right == 0 {
+			return &Error{Kind: DivisionByZeroError, Message: "division by zero"}
+		}
+		return Float{Value: left / right}
+	case token.Modulo:
+		if right == 0 {
+			return &Error{Kind: ModuloByZeroError, Message: "modulo by zero"}
+		}
+		return Float{Value: math.Mod(left, right)}
+	case token.Equals:
+		return Boolean{Value: left == right}
+	case token.NotEquals:
+		return Boolean{Value: left != right}
+	case token.LessThan:
+		return Boolean{Value: left < right}
+	case token.GreaterThan:
+		return Boolean{Value: left > right}
+	case token.LessThanOrEquals:
+		return Boolean{Value: left <= right}
+	case token.GreaterThanOrEquals:
+		return Boolean{Value: left >= right}
+	default:
+		return &Error{Kind: UnsupportedError, Message: "unknown operator '" + string(op) + "'"}
+	}
+}
+
+func stringBinaryOperation(op token.Type, left, right string) Object {
+	switch op {
+	case token.Add:
+		return String{Value: left + right}
+	default:
+		return &Error{Kind: UnsupportedError, Message: "operator '" + string(op) + "' is not supported for " + string(StringObj)}
+	}
+}
+
+func typeMismatchError(op token.Type, left, right Object) *Error {
+	return &Error{
+		Kind:    TypeMismatchError,
+		Message: "type mismatch: " + string(left.Type()) + " " + string(op) + " " + string(right.Type()),
+	}
+}
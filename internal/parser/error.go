@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/avearmin/simple/internal/diagnostics"
+	"github.com/avearmin/simple/internal/lexer"
+	"github.com/avearmin/simple/internal/token"
+)
+
+// Error is a syntax error. It is a diagnostic, whose Expected and Found are
+// set when it is about a token that does not belong where it is.
+type Error = diagnostics.Diagnostic
+
+// ErrorList is the syntax errors of a program, in the order they were found.
+// ParseProgram returns it as its error.
+type ErrorList = diagnostics.List
+
+// errorAt creates a diagnostic reported at tok.
+func errorAt(code diagnostics.Code, tok token.Token, format string, a ...any) *diagnostics.Diagnostic {
+	return diagnostics.New(code, diagnostics.TokenSpan(tok), format, a...)
+}
+
+// unexpected creates a diagnostic for a tok that is none of the expected
+// types.
+func unexpected(tok token.Token, expected ...token.Type) *diagnostics.Diagnostic {
+	names := make([]string, len(expected))
+	for i, tokType := range expected {
+		names[i] = fmt.Sprintf("'%s'", tokType)
+	}
+	return expectedError(tok, strings.Join(names, " or "), expected)
+}
+
+// expectedError creates a diagnostic for a tok found where what was
+// expected, which begins with a token of one of the expected types. An
+// Illegal tok is reported with the lexer's explanation of it instead.
+func expectedError(tok token.Token, what string, expected []token.Type) *diagnostics.Diagnostic {
+	var d *diagnostics.Diagnostic
+	switch tok.Type {
+	case token.Illegal:
+		d = lexer.Diagnose(tok)
+	case token.EOF:
+		d = errorAt(diagnostics.UnexpectedToken, tok, "expected %s, but got '%s'", what, tok.Type).
+			Note("the input ended before every '(' was closed")
+		if len(expected) == 1 && expected[0] == token.RParen {
+			d.Fix = &diagnostics.Fix{Message: "insert ')'", Span: diagnostics.TokenSpan(tok), Replacement: ")"}
+		}
+	default:
+		d = errorAt(diagnostics.UnexpectedToken, tok, "expected %s, but got '%s'", what, tok.Type)
+	}
+
+	d.Expected = expected
+	d.Found = tok.Type
+	return d
+}
@@ -1,17 +1,52 @@
 package parser
 
 import (
-	"fmt"
+	"slices"
 
 	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/diagnostics"
 	"github.com/avearmin/simple/internal/lexer"
 	"github.com/avearmin/simple/internal/token"
 )
 
+// The types of the tokens that can begin each kind of construct, which are
+// reported as expected when some other token is found.
+var (
+	statementStarts = []token.Type{
+		token.Assign, token.Reassign, token.If, token.Fn, token.Return, token.For,
+		token.While, token.Break, token.Continue, token.Ident, token.LParen,
+	}
+	expressionStarts = []token.Type{
+		token.LParen, token.Ident, token.Int, token.Float, token.Bool, token.String, token.Nil,
+	}
+	binaryOperators = []token.Type{
+		token.Add, token.Subtract, token.Multiply, token.Divide, token.Modulo, token.Equals, token.NotEquals,
+		token.LessThanOrEquals, token.GreaterThanOrEquals, token.LessThan, token.GreaterThan,
+	}
+	listExpressionStarts = slices.Concat(binaryOperators, []token.Type{
+		token.List, token.And, token.Or, token.Not, token.Fn, token.Ident, token.LParen,
+	})
+)
+
 type Parser struct {
-	l         *lexer.Lexer
+	l *lexer.Lexer
+
+	// prevToken is the token before curToken. While the first token of a
+	// list is current, it is the list's '('.
+	prevToken token.Token
 	curToken  token.Token
 	peekToken token.Token
+
+	// comments are the comments carried by every token read so far.
+	comments []token.Comment
+
+	// depth is the number of parentheses opened and not yet closed before
+	// curToken.
+	depth int
+
+	// loopDepth is the number of loops the current statement is in, counting
+	// only those inside the innermost function.
+	loopDepth int
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -24,32 +59,72 @@ func New(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) nextToken() {
+	switch {
+	case p.curToken.Type == token.LParen:
+		p.depth++
+	case p.curToken.Type == token.RParen && p.depth > 0:
+		p.depth--
+	}
+
+	p.prevToken = p.curToken
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	p.comments = append(p.comments, p.peekToken.Comments...)
 }
 
+// ParseProgram parses every top level statement of the input. A statement
+// with a syntax error is skipped, up to its closing parenthesis, and parsing
+// goes on from the statement after it. If there were any errors, they are
+// returned as a diagnostics.List along with the statements that did parse.
 func (p *Parser) ParseProgram() (*ast.Program, error) {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
+	var errs diagnostics.List
+
+	p.ignoreDelimiters()
 	for p.curToken.Type != token.EOF {
 		stmt, err := p.parseStatement()
 		if err != nil {
-			return nil, err
+			d, ok := err.(*diagnostics.Diagnostic)
+			if !ok {
+				d = errorAt(diagnostics.UnexpectedToken, p.curToken, "%s", err)
+			}
+			errs = append(errs, d)
+			p.synchronize()
+		} else {
+			program.Statements = append(program.Statements, stmt)
 		}
 
-		program.Statements = append(program.Statements, stmt)
-
 		p.ignoreDelimiters()
 	}
+	program.Comments = p.comments
 
+	if len(errs) > 0 {
+		return program, errs
+	}
 	return program, nil
 }
 
+// synchronize skips the rest of the top level statement a syntax error was
+// found in. If the error was outside of any statement, it skips to the next
+// '('.
+func (p *Parser) synchronize() {
+	if p.depth == 0 {
+		for !p.expectCur(token.LParen) && !p.expectCur(token.EOF) {
+			p.nextToken()
+		}
+		return
+	}
+
+	for p.depth > 0 && !p.expectCur(token.EOF) {
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseStatement() (ast.Statement, error) {
 	if !p.expectCur(token.LParen) {
-		return nil, fmt.Errorf("expected token '(' on line %d col %d, but got '%s'",
-			p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return nil, unexpected(p.curToken, token.LParen)
 	}
 	p.nextToken()
 
@@ -85,29 +160,39 @@ func (p *Parser) parseStatement() (ast.Statement, error) {
 		}
 		return stmt, nil
 	case token.For:
-		stmt, err := p.parseForLoopStatement()
+		stmt, err := p.parseForStatement()
 		if err != nil {
 			return nil, err
 		}
 		return stmt, nil
-	case token.Ident:
+	case token.While:
+		stmt, err := p.parseWhileStatement()
+		if err != nil {
+			return nil, err
+		}
+		return stmt, nil
+	case token.Break, token.Continue:
+		stmt, err := p.parseLoopControlStatement()
+		if err != nil {
+			return nil, err
+		}
+		return stmt, nil
+	case token.Ident, token.LParen:
 		stmt, err := p.parseFnCall()
 		if err != nil {
 			return nil, err
 		}
 		return stmt, nil
 	default:
-		return nil, fmt.Errorf("cannot begin statement with token '%s' on line %d col %d",
-			p.curToken.Type, p.curToken.Line, p.curToken.Col)
+		return nil, expectedError(p.curToken, "a statement", statementStarts)
 	}
 }
 
 func (p *Parser) parseAssignStatement() (ast.AssignStatement, error) {
 	if !p.expectCur(token.Assign) {
-		return ast.AssignStatement{}, fmt.Errorf("expected token ':=' on line %d col %d but got '%s'",
-			p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return ast.AssignStatement{}, unexpected(p.curToken, token.Assign)
 	}
-	stmt := ast.AssignStatement{Token: p.curToken}
+	stmt := ast.AssignStatement{Token: p.curToken, LParen: p.prevToken}
 	p.nextToken()
 
 	if err := p.eatDelimiter(); err != nil {
@@ -119,8 +204,7 @@ func (p *Parser) parseAssignStatement() (ast.AssignStatement, error) {
 		return ast.AssignStatement{}, err
 	}
 	if atom.TokenType() != token.Ident {
-		return ast.AssignStatement{}, fmt.Errorf("expected token 'IDENT' on line %d col %d, but got %s",
-			atom.Token.Line, atom.Token.Col, atom.TokenType())
+		return ast.AssignStatement{}, unexpected(atom.Token, token.Ident)
 	}
 	stmt.Name = atom
 
@@ -135,10 +219,10 @@ func (p *Parser) parseAssignStatement() (ast.AssignStatement, error) {
 	stmt.Value = exp
 
 	if !p.expectCur(token.RParen) {
-		return ast.AssignStatement{}, fmt.Errorf("expected token ')' on line %d col %d, but got '%s'",
-			p.curToken.Line, p.curToken.Col, p.peekToken.Type)
+		return ast.AssignStatement{}, unexpected(p.curToken, token.RParen)
 	}
 
+	stmt.RParen = p.curToken
 	p.nextToken()
 
 	return stmt, nil
@@ -146,10 +230,9 @@ func (p *Parser) parseAssignStatement() (ast.AssignStatement, error) {
 
 func (p *Parser) parseReassignStatement() (ast.ReassignStatement, error) {
 	if !p.expectCur(token.Reassign) {
-		return ast.ReassignStatement{}, fmt.Errorf("expected token '=' on line %d col %d, but got '%s'",
-			p.curToken.Line, p.curToken.Col, p.peekToken.Type)
+		return ast.ReassignStatement{}, unexpected(p.curToken, token.Reassign)
 	}
-	stmt := ast.ReassignStatement{Token: p.curToken}
+	stmt := ast.ReassignStatement{Token: p.curToken, LParen: p.prevToken}
 	p.nextToken()
 
 	if err := p.eatDelimiter(); err != nil {
@@ -161,8 +244,7 @@ func (p *Parser) parseReassignStatement() (ast.ReassignStatement, error) {
 		return ast.ReassignStatement{}, err
 	}
 	if atom.TokenType() != token.Ident {
-		return ast.ReassignStatement{}, fmt.Errorf("expected token 'IDENT' on line %d col %d, but got %s",
-			atom.Token.Line, atom.Token.Col, atom.TokenType())
+		return ast.ReassignStatement{}, unexpected(atom.Token, token.Ident)
 	}
 	stmt.Name = atom
 
@@ -177,9 +259,9 @@ func (p *Parser) parseReassignStatement() (ast.ReassignStatement, error) {
 	stmt.Value = exp
 
 	if !p.expectCur(token.RParen) {
-		return ast.ReassignStatement{}, fmt.Errorf("expected token ')' on line %d col %d, but got '%s'",
-			p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return ast.ReassignStatement{}, unexpected(p.curToken, token.RParen)
 	}
+	stmt.RParen = p.curToken
 	p.nextToken()
 
 	return stmt, nil
@@ -187,11 +269,11 @@ func (p *Parser) parseReassignStatement() (ast.ReassignStatement, error) {
 
 func (p *Parser) parseConditionalStatement() (ast.ConditionalStatement, error) {
 	if !p.expectCur(token.If) {
-		return ast.ConditionalStatement{}, fmt.Errorf("expected token 'IF' on line %d col %d, but got '%s'",
-			p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return ast.ConditionalStatement{}, unexpected(p.curToken, token.If)
 	}
 	stmt := ast.ConditionalStatement{
 		Token:        p.curToken,
+		LParen:       p.prevToken,
 		IfStatements: []ast.Statement{},
 		ElifBlocks:   []ast.ElifBlock{},
 	}
@@ -211,45 +293,37 @@ func (p *Parser) parseConditionalStatement() (ast.ConditionalStatement, error) {
 		return ast.ConditionalStatement{}, err
 	}
 
-	for !p.expectCur(token.Elif) && !p.expectCur(token.Else) {
+	for !p.expectCur(token.Elif) && !p.expectCur(token.Else) && !p.expectCur(token.RParen) {
 		ifStmt, err := p.parseStatement()
 		if err != nil {
 			return ast.ConditionalStatement{}, err
 		}
 		stmt.IfStatements = append(stmt.IfStatements, ifStmt)
 
-		if p.expectCur(token.RParen) {
-			return stmt, nil
-		}
-
-		if err := p.eatDelimiter(); err != nil {
-			return ast.ConditionalStatement{}, err
-		}
+		p.ignoreDelimiters()
 	}
 
-	for !p.expectCur(token.Else) {
+	for p.expectCur(token.Elif) {
 		elifBlock, err := p.parseElifBlock()
 		if err != nil {
 			return ast.ConditionalStatement{}, err
 		}
 		stmt.ElifBlocks = append(stmt.ElifBlocks, elifBlock)
-
-		if p.expectCur(token.RParen) {
-			return stmt, nil
-		}
 	}
 
-	elseBlock, err := p.parseElseBlock()
-	if err != nil {
-		return ast.ConditionalStatement{}, err
+	if p.expectCur(token.Else) {
+		elseBlock, err := p.parseElseBlock()
+		if err != nil {
+			return ast.ConditionalStatement{}, err
+		}
+		stmt.ElseBlock = elseBlock
 	}
-	stmt.ElseBlock = elseBlock
 
 	if !p.expectCur(token.RParen) {
-		return ast.ConditionalStatement{}, fmt.Errorf("expected token ')' on line %d col %d, but got '%s'",
-			p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return ast.ConditionalStatement{}, unexpected(p.curToken, token.RParen)
 	}
 
+	stmt.RParen = p.curToken
 	p.nextToken()
 
 	return stmt, nil
@@ -257,8 +331,7 @@ func (p *Parser) parseConditionalStatement() (ast.ConditionalStatement, error) {
 
 func (p *Parser) parseElifBlock() (ast.ElifBlock, error) {
 	if !p.expectCur(token.Elif) {
-		return ast.ElifBlock{}, fmt.Errorf("expected token 'ELIF' on line %d col %d but got %s",
-			p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return ast.ElifBlock{}, unexpected(p.curToken, token.Elif)
 	}
 
 	block := ast.ElifBlock{Token: p.curToken, Statements: []ast.Statement{}}
@@ -278,13 +351,14 @@ func (p *Parser) parseElifBlock() (ast.ElifBlock, error) {
 		return ast.ElifBlock{}, err
 	}
 
-	for p.curToken.Type != token.Elif && p.curToken.Type != token.Else && p.peekToken.Type != token.RParen {
+	for !p.expectCur(token.Elif) && !p.expectCur(token.Else) && !p.expectCur(token.RParen) {
 		stmt, err := p.parseStatement()
 		if err != nil {
 			return ast.ElifBlock{}, err
 		}
 		block.Statements = append(block.Statements, stmt)
-		p.eatDelimiter()
+
+		p.ignoreDelimiters()
 	}
 
 	return block, nil
@@ -292,8 +366,7 @@ func (p *Parser) parseElifBlock() (ast.ElifBlock, error) {
 
 func (p *Parser) parseElseBlock() (ast.ElseBlock, error) {
 	if !p.expectCur(token.Else) {
-		return ast.ElseBlock{}, fmt.Errorf("expected token 'ELSE' on line %d col %d but got %s",
-			p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return ast.ElseBlock{}, unexpected(p.curToken, token.Else)
 	}
 
 	block := ast.ElseBlock{Token: p.curToken, Statements: []ast.Statement{}}
@@ -309,6 +382,8 @@ func (p *Parser) parseElseBlock() (ast.ElseBlock, error) {
 			return ast.ElseBlock{}, err
 		}
 		block.Statements = append(block.Statements, stmt)
+
+		p.ignoreDelimiters()
 	}
 
 	return block, nil
@@ -316,9 +391,9 @@ func (p *Parser) parseElseBlock() (ast.ElseBlock, error) {
 
 func (p *Parser) parseFunctionAssignStatement() (ast.FunctionAssignStatement, error) {
 	if !p.expectCur(token.Fn) {
-		return ast.FunctionAssignStatement{}, fmt.Errorf("%d:%d expected 'FN', got '%s'", p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return ast.FunctionAssignStatement{}, unexpected(p.curToken, token.Fn)
 	}
-	fnStmt := ast.FunctionAssignStatement{Token: p.curToken, Params: []ast.Atom{}, Statements: []ast.Statement{}}
+	fnStmt := ast.FunctionAssignStatement{Token: p.curToken, LParen: p.prevToken, Params: []ast.Atom{}, Statements: []ast.Statement{}}
 	p.nextToken()
 
 	if err := p.eatDelimiter(); err != nil {
@@ -330,7 +405,7 @@ func (p *Parser) parseFunctionAssignStatement() (ast.FunctionAssignStatement, er
 		return ast.FunctionAssignStatement{}, err
 	}
 	if fnName.TokenType() != token.Ident {
-		return ast.FunctionAssignStatement{}, fmt.Errorf("%d:%d cannot use '%s' as name of a function", p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return ast.FunctionAssignStatement{}, expectedError(fnName.Token, "the name of a function", []token.Type{token.Ident})
 	}
 	fnStmt.Name = fnName
 
@@ -344,13 +419,14 @@ func (p *Parser) parseFunctionAssignStatement() (ast.FunctionAssignStatement, er
 			return ast.FunctionAssignStatement{}, err
 		}
 		if param.TokenType() != token.Ident {
-			return ast.FunctionAssignStatement{}, fmt.Errorf("%d:%d cannot use '%s' as parameter to a function", p.curToken.Line, p.curToken.Col, p.curToken.Type)
-
+			return ast.FunctionAssignStatement{}, expectedError(param.Token, "the name of a parameter", []token.Type{token.Ident})
 		}
 
 		fnStmt.Params = append(fnStmt.Params, param)
 
 		if p.expectCur(token.RParen) {
+			fnStmt.RParen = p.curToken
+			p.nextToken()
 			return fnStmt, nil
 		}
 
@@ -360,6 +436,8 @@ func (p *Parser) parseFunctionAssignStatement() (ast.FunctionAssignStatement, er
 
 	}
 
+	defer p.enterFunction()()
+
 	for {
 		innerStmt, err := p.parseStatement()
 		if err != nil {
@@ -367,24 +445,22 @@ func (p *Parser) parseFunctionAssignStatement() (ast.FunctionAssignStatement, er
 		}
 		fnStmt.Statements = append(fnStmt.Statements, innerStmt)
 
+		p.ignoreDelimiters()
 		if p.expectCur(token.RParen) {
 			break
 		}
-
-		if err := p.eatDelimiter(); err != nil {
-			return ast.FunctionAssignStatement{}, err
-		}
 	}
 
+	fnStmt.RParen = p.curToken
 	p.nextToken()
 	return fnStmt, nil
 }
 
 func (p *Parser) parseReturnStatement() (ast.ReturnStatement, error) {
 	if !p.expectCur(token.Return) {
-		return ast.ReturnStatement{}, fmt.Errorf("%d:%d expected 'RETURN' but got '%s'", p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return ast.ReturnStatement{}, unexpected(p.curToken, token.Return)
 	}
-	returnStmt := ast.ReturnStatement{Token: p.curToken}
+	returnStmt := ast.ReturnStatement{Token: p.curToken, LParen: p.prevToken}
 	p.nextToken()
 
 	if err := p.eatDelimiter(); err != nil {
@@ -399,69 +475,86 @@ func (p *Parser) parseReturnStatement() (ast.ReturnStatement, error) {
 	returnStmt.Value = exp
 
 	if !p.expectCur(token.RParen) {
-		return ast.ReturnStatement{}, fmt.Errorf("%d:%d expected ')' but got %s", p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return ast.ReturnStatement{}, unexpected(p.curToken, token.RParen)
 	}
+	returnStmt.RParen = p.curToken
 	p.nextToken()
 
 	return returnStmt, nil
 }
 
-func (p *Parser) parseForLoopStatement() (ast.ForLoopStatement, error) {
+// parseForStatement parses either kind of for loop. A name after the 'for'
+// begins a for-each loop, and anything else the header of a for loop.
+func (p *Parser) parseForStatement() (ast.Statement, error) {
 	if !p.expectCur(token.For) {
-		return ast.ForLoopStatement{}, fmt.Errorf("%d:%d expected 'FOR' but got %s", p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return nil, unexpected(p.curToken, token.For)
 	}
-	forLoopStmt := ast.ForLoopStatement{Token: p.curToken, Statements: []ast.Statement{}}
+	lparen, forTok := p.prevToken, p.curToken
 	p.nextToken()
 
 	if err := p.eatDelimiter(); err != nil {
-		return ast.ForLoopStatement{}, err
+		return nil, err
 	}
 
-	if !p.expectCur(token.LParen) {
-		return ast.ForLoopStatement{}, fmt.Errorf("%d:%d expected '(' but got %s", p.curToken.Line, p.curToken.Col, p.curToken.Type)
+	if p.expectCur(token.Ident) {
+		stmt, err := p.parseForEachStatement(forTok)
+		if err != nil {
+			return nil, err
+		}
+		stmt.LParen = lparen
+		return stmt, nil
 	}
-	p.nextToken()
 
-	initalizer, err := p.parseAssignStatement()
+	stmt, err := p.parseForLoopStatement(forTok)
 	if err != nil {
-		return ast.ForLoopStatement{}, err
-	}
-	forLoopStmt.Initalizer = initalizer
-
-	if err := p.eatDelimiter(); err != nil {
-		return ast.ForLoopStatement{}, err
+		return nil, err
 	}
+	stmt.LParen = lparen
+	return stmt, nil
+}
 
-	if !p.expectCur(token.LParen) {
-		return ast.ForLoopStatement{}, fmt.Errorf("%d:%d expected '(' but got %s", p.curToken.Line, p.curToken.Col, p.curToken.Type)
-	}
-	p.nextToken()
+func (p *Parser) parseForLoopStatement(forTok token.Token) (ast.ForLoopStatement, error) {
+	forLoopStmt := ast.ForLoopStatement{Token: forTok, Statements: []ast.Statement{}}
 
-	condition, err := p.parseBinaryExpression()
-	if err != nil {
-		return ast.ForLoopStatement{}, err
+	if !p.skipEmptySlot() {
+		initalizer, err := p.parseStatement()
+		if err != nil {
+			return ast.ForLoopStatement{}, err
+		}
+		forLoopStmt.Initalizer = initalizer
 	}
-	forLoopStmt.Condition = condition
 
 	if err := p.eatDelimiter(); err != nil {
 		return ast.ForLoopStatement{}, err
 	}
 
-	if !p.expectCur(token.LParen) {
-		return ast.ForLoopStatement{}, fmt.Errorf("%d:%d expected '(' but got %s", p.curToken.Line, p.curToken.Col, p.curToken.Type)
+	if !p.skipEmptySlot() {
+		condition, err := p.parseExpression()
+		if err != nil {
+			return ast.ForLoopStatement{}, err
+		}
+		forLoopStmt.Condition = condition
 	}
-	p.nextToken()
 
-	update, err := p.parseReassignStatement()
-	if err != nil {
+	if err := p.eatDelimiter(); err != nil {
 		return ast.ForLoopStatement{}, err
 	}
-	forLoopStmt.Update = update
+
+	if !p.skipEmptySlot() {
+		update, err := p.parseStatement()
+		if err != nil {
+			return ast.ForLoopStatement{}, err
+		}
+		forLoopStmt.Update = update
+	}
 
 	if err := p.eatDelimiter(); err != nil {
 		return ast.ForLoopStatement{}, err
 	}
 
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
 	for {
 		innerStmt, err := p.parseStatement()
 		if err != nil {
@@ -470,52 +563,227 @@ func (p *Parser) parseForLoopStatement() (ast.ForLoopStatement, error) {
 
 		forLoopStmt.Statements = append(forLoopStmt.Statements, innerStmt)
 
+		p.ignoreDelimiters()
 		if p.expectCur(token.RParen) {
 			break
 		}
+	}
+
+	forLoopStmt.RParen = p.curToken
+	p.nextToken()
+
+	return forLoopStmt, nil
+}
+
+// parseForEachStatement parses the rest of a for-each loop: one or two names,
+// the 'in' keyword, the iterable and the body.
+func (p *Parser) parseForEachStatement(forTok token.Token) (ast.ForEachStatement, error) {
+	forEachStmt := ast.ForEachStatement{Token: forTok, Statements: []ast.Statement{}}
+
+	for len(forEachStmt.Names) < 2 && p.expectCur(token.Ident) {
+		name, err := p.parseAtomExpression()
+		if err != nil {
+			return ast.ForEachStatement{}, err
+		}
+		forEachStmt.Names = append(forEachStmt.Names, name)
 
 		if err := p.eatDelimiter(); err != nil {
-			return ast.ForLoopStatement{}, err
+			return ast.ForEachStatement{}, err
 		}
 	}
 
+	if !p.expectCur(token.In) {
+		return ast.ForEachStatement{}, unexpected(p.curToken, token.In)
+	}
 	p.nextToken()
 
-	return forLoopStmt, nil
+	if err := p.eatDelimiter(); err != nil {
+		return ast.ForEachStatement{}, err
+	}
+
+	iterable, err := p.parseExpression()
+	if err != nil {
+		return ast.ForEachStatement{}, err
+	}
+	forEachStmt.Iterable = iterable
+
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	p.ignoreDelimiters()
+	for !p.expectCur(token.RParen) {
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return ast.ForEachStatement{}, err
+		}
+		forEachStmt.Statements = append(forEachStmt.Statements, stmt)
+
+		p.ignoreDelimiters()
+	}
+	forEachStmt.RParen = p.curToken
+	p.nextToken()
+
+	return forEachStmt, nil
 }
 
-func (p *Parser) parseFnCall() (ast.FnCall, error) {
-	if !p.expectCur(token.Ident) {
-		return ast.FnCall{}, fmt.Errorf("%d:%d expected 'IDENT' but got '%s'", p.curToken.Line, p.curToken.Col, p.curToken.Type)
+func (p *Parser) parseWhileStatement() (ast.WhileStatement, error) {
+	if !p.expectCur(token.While) {
+		return ast.WhileStatement{}, unexpected(p.curToken, token.While)
 	}
-	fnCall := ast.FnCall{Token: p.curToken, Arguments: []ast.Atom{}}
+	whileStmt := ast.WhileStatement{Token: p.curToken, LParen: p.prevToken, Statements: []ast.Statement{}}
 	p.nextToken()
 
 	if err := p.eatDelimiter(); err != nil {
-		return ast.FnCall{}, err
+		return ast.WhileStatement{}, err
 	}
 
-	for {
-		arg, err := p.parseAtomExpression()
+	condition, err := p.parseExpression()
+	if err != nil {
+		return ast.WhileStatement{}, err
+	}
+	whileStmt.Condition = condition
+
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	p.ignoreDelimiters()
+	for !p.expectCur(token.RParen) {
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return ast.WhileStatement{}, err
+		}
+		whileStmt.Statements = append(whileStmt.Statements, stmt)
+
+		p.ignoreDelimiters()
+	}
+	whileStmt.RParen = p.curToken
+	p.nextToken()
+
+	return whileStmt, nil
+}
+
+// parseLoopControlStatement parses a break or continue, which may only
+// appear inside the body of a loop.
+func (p *Parser) parseLoopControlStatement() (ast.Statement, error) {
+	lparen, tok := p.prevToken, p.curToken
+	if tok.Type != token.Break && tok.Type != token.Continue {
+		return nil, unexpected(tok, token.Break, token.Continue)
+	}
+	if p.loopDepth == 0 {
+		return nil, errorAt(diagnostics.OutsideLoop, tok, "'%s' is not inside a loop", tok.Literal).
+			Note("'%s' can only be used in the body of a loop, and not in a function inside one", tok.Literal)
+	}
+	p.nextToken()
+
+	if !p.expectCur(token.RParen) {
+		return nil, unexpected(p.curToken, token.RParen)
+	}
+	rparen := p.curToken
+	p.nextToken()
+
+	if tok.Type == token.Break {
+		return ast.BreakStatement{Token: tok, LParen: lparen, RParen: rparen}, nil
+	}
+	return ast.ContinueStatement{Token: tok, LParen: lparen, RParen: rparen}, nil
+}
+
+// parseFnCall parses a call of a named function, or of any expression in
+// parentheses that evaluates to a function.
+func (p *Parser) parseFnCall() (ast.FnCall, error) {
+	fnCall := ast.FnCall{Token: p.curToken, LParen: p.prevToken, Arguments: []ast.Expression{}}
+
+	switch p.curToken.Type {
+	case token.Ident:
+		callee, err := p.parseAtomExpression()
 		if err != nil {
 			return ast.FnCall{}, err
 		}
-		fnCall.Arguments = append(fnCall.Arguments, arg)
+		fnCall.Callee = callee
+	case token.LParen:
+		callee, err := p.parseExpression()
+		if err != nil {
+			return ast.FnCall{}, err
+		}
+		fnCall.Callee = callee
+	default:
+		return ast.FnCall{}, unexpected(p.curToken, token.Ident, token.LParen)
+	}
 
+	for !p.expectCur(token.RParen) {
+		if err := p.eatDelimiter(); err != nil {
+			return ast.FnCall{}, err
+		}
 		if p.expectCur(token.RParen) {
 			break
 		}
 
-		if err := p.eatDelimiter(); err != nil {
+		arg, err := p.parseExpression()
+		if err != nil {
 			return ast.FnCall{}, err
 		}
+		fnCall.Arguments = append(fnCall.Arguments, arg)
 	}
 
+	fnCall.RParen = p.curToken
 	p.nextToken()
 
 	return fnCall, nil
 }
 
+// parseFunctionLiteral parses an anonymous function, whose parameters are
+// given in a list of their own: (fn (x y) (return (+ x y))).
+func (p *Parser) parseFunctionLiteral() (ast.FunctionLiteral, error) {
+	if !p.expectCur(token.Fn) {
+		return ast.FunctionLiteral{}, unexpected(p.curToken, token.Fn)
+	}
+	fnLit := ast.FunctionLiteral{Token: p.curToken, LParen: p.prevToken, Params: []ast.Atom{}, Statements: []ast.Statement{}}
+	p.nextToken()
+
+	if err := p.eatDelimiter(); err != nil {
+		return ast.FunctionLiteral{}, err
+	}
+
+	if !p.expectCur(token.LParen) {
+		return ast.FunctionLiteral{}, unexpected(p.curToken, token.LParen)
+	}
+	p.nextToken()
+
+	for !p.expectCur(token.RParen) {
+		if len(fnLit.Params) > 0 {
+			if err := p.eatDelimiter(); err != nil {
+				return ast.FunctionLiteral{}, err
+			}
+		}
+
+		param, err := p.parseAtomExpression()
+		if err != nil {
+			return ast.FunctionLiteral{}, err
+		}
+		if param.TokenType() != token.Ident {
+			return ast.FunctionLiteral{}, expectedError(param.Token, "the name of a parameter", []token.Type{token.Ident})
+		}
+		fnLit.Params = append(fnLit.Params, param)
+	}
+	p.nextToken()
+
+	defer p.enterFunction()()
+
+	p.ignoreDelimiters()
+	for !p.expectCur(token.RParen) {
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return ast.FunctionLiteral{}, err
+		}
+		fnLit.Statements = append(fnLit.Statements, stmt)
+
+		p.ignoreDelimiters()
+	}
+	fnLit.RParen = p.curToken
+	p.nextToken()
+
+	return fnLit, nil
+}
+
 // expressions are either lists, or atoms
 func (p *Parser) parseExpression() (ast.Expression, error) {
 	switch p.curToken.Type {
@@ -526,16 +794,14 @@ func (p *Parser) parseExpression() (ast.Expression, error) {
 			return nil, err
 		}
 		return exp, nil
-	case token.Ident, token.Int, token.Bool:
+	case token.Ident, token.Int, token.Float, token.Bool, token.String, token.Nil:
 		atom, err := p.parseAtomExpression()
 		if err != nil {
 			return nil, err
 		}
 		return atom, nil
 	default:
-		err := fmt.Errorf("Unexpected token '%s' on line %d col %d",
-			p.curToken.Type, p.curToken.Line, p.curToken.Col)
-		return nil, err
+		return nil, expectedError(p.curToken, "an expression", expressionStarts)
 	}
 }
 
@@ -547,9 +813,38 @@ func (p *Parser) parseListExpression() (ast.Expression, error) {
 			return nil, err
 		}
 		return exp, nil
+	case token.List:
+		exp, err := p.parseListLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return exp, nil
+	case token.And, token.Or:
+		exp, err := p.parseLogicalExpression()
+		if err != nil {
+			return nil, err
+		}
+		return exp, nil
+	case token.Not:
+		exp, err := p.parseUnaryExpression()
+		if err != nil {
+			return nil, err
+		}
+		return exp, nil
+	case token.Fn:
+		exp, err := p.parseFunctionLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return exp, nil
+	case token.Ident, token.LParen:
+		exp, err := p.parseFnCall()
+		if err != nil {
+			return nil, err
+		}
+		return exp, nil
 	default:
-		return nil, fmt.Errorf("Unexpected token on line %d col %d in list expression '%s'",
-			p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return nil, expectedError(p.curToken, "an operator or a call", listExpressionStarts)
 	}
 }
 
@@ -558,9 +853,9 @@ func (p *Parser) parseBinaryExpression() (ast.BinaryExpression, error) {
 	switch p.curToken.Type {
 	case "+", "-", "*", "/", "%", "==", "!=", "<=", ">=", "<", ">":
 		binaryExp.Token = p.curToken
+		binaryExp.LParen = p.prevToken
 	default:
-		return ast.BinaryExpression{}, fmt.Errorf("Cannot begin binary expression with '%s' on line %d col %d",
-			p.curToken.Type, p.curToken.Line, p.curToken.Col)
+		return ast.BinaryExpression{}, expectedError(p.curToken, "a binary operator", binaryOperators)
 	}
 	p.nextToken()
 
@@ -575,7 +870,7 @@ func (p *Parser) parseBinaryExpression() (ast.BinaryExpression, error) {
 	binaryExp.First = expOne
 
 	if err := p.eatDelimiter(); err != nil {
-		return ast.BinaryExpression{}, nil
+		return ast.BinaryExpression{}, err
 	}
 
 	expTwo, err := p.parseExpression()
@@ -585,24 +880,128 @@ func (p *Parser) parseBinaryExpression() (ast.BinaryExpression, error) {
 	binaryExp.Second = expTwo
 
 	if !p.expectCur(token.RParen) {
-		return ast.BinaryExpression{}, fmt.Errorf("expected token ')' on line %d col %d, but got '%s'",
-			p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return ast.BinaryExpression{}, unexpected(p.curToken, token.RParen)
 	}
+	binaryExp.RParen = p.curToken
 	p.nextToken()
 
 	return binaryExp, nil
 }
 
+func (p *Parser) parseListLiteral() (ast.ListLiteral, error) {
+	if !p.expectCur(token.List) {
+		return ast.ListLiteral{}, unexpected(p.curToken, token.List)
+	}
+	list := ast.ListLiteral{Token: p.curToken, LParen: p.prevToken, Elements: []ast.Expression{}}
+	p.nextToken()
+
+	for !p.expectCur(token.RParen) {
+		if err := p.eatDelimiter(); err != nil {
+			return ast.ListLiteral{}, err
+		}
+		if p.expectCur(token.RParen) {
+			break
+		}
+
+		exp, err := p.parseExpression()
+		if err != nil {
+			return ast.ListLiteral{}, err
+		}
+		list.Elements = append(list.Elements, exp)
+	}
+	list.RParen = p.curToken
+	p.nextToken()
+
+	return list, nil
+}
+
+func (p *Parser) parseLogicalExpression() (ast.LogicalExpression, error) {
+	if !p.expectCur(token.And) && !p.expectCur(token.Or) {
+		return ast.LogicalExpression{}, unexpected(p.curToken, token.And, token.Or)
+	}
+	logicalExp := ast.LogicalExpression{Token: p.curToken, LParen: p.prevToken, Operands: []ast.Expression{}}
+	p.nextToken()
+
+	for !p.expectCur(token.RParen) {
+		if err := p.eatDelimiter(); err != nil {
+			return ast.LogicalExpression{}, err
+		}
+		if p.expectCur(token.RParen) {
+			break
+		}
+
+		exp, err := p.parseExpression()
+		if err != nil {
+			return ast.LogicalExpression{}, err
+		}
+		logicalExp.Operands = append(logicalExp.Operands, exp)
+	}
+
+	if len(logicalExp.Operands) < 2 {
+		return ast.LogicalExpression{}, errorAt(diagnostics.TooFewOperands, logicalExp.Token, "'%s' expects at least 2 operands, but got %d",
+			logicalExp.Token.Literal, len(logicalExp.Operands))
+	}
+	logicalExp.RParen = p.curToken
+	p.nextToken()
+
+	return logicalExp, nil
+}
+
+func (p *Parser) parseUnaryExpression() (ast.UnaryExpression, error) {
+	if !p.expectCur(token.Not) {
+		return ast.UnaryExpression{}, unexpected(p.curToken, token.Not)
+	}
+	unaryExp := ast.UnaryExpression{Token: p.curToken, LParen: p.prevToken}
+	p.nextToken()
+
+	if err := p.eatDelimiter(); err != nil {
+		return ast.UnaryExpression{}, err
+	}
+
+	exp, err := p.parseExpression()
+	if err != nil {
+		return ast.UnaryExpression{}, err
+	}
+	unaryExp.Operand = exp
+
+	if !p.expectCur(token.RParen) {
+		return ast.UnaryExpression{}, unexpected(p.curToken, token.RParen)
+	}
+	unaryExp.RParen = p.curToken
+	p.nextToken()
+
+	return unaryExp, nil
+}
+
 func (p *Parser) parseAtomExpression() (ast.Atom, error) {
-	if !p.expectCur(token.Ident) && !p.expectCur(token.Int) && !p.expectCur(token.Bool) {
-		return ast.Atom{}, fmt.Errorf("expected token 'IDENT' on line %d col %d, but got %s",
-			p.curToken.Line, p.curToken.Col, p.curToken.Type)
+	if !p.expectCur(token.Ident) && !p.expectCur(token.Int) && !p.expectCur(token.Float) && !p.expectCur(token.Bool) &&
+		!p.expectCur(token.String) && !p.expectCur(token.Nil) {
+		return ast.Atom{}, unexpected(p.curToken, token.Ident)
 	}
 	atom := ast.Atom{Token: p.curToken, Value: p.curToken.Literal}
 	p.nextToken()
 	return atom, nil
 }
 
+// enterFunction starts the body of a function, where the loops around the
+// function do not count, and returns a func that ends it.
+func (p *Parser) enterFunction() func() {
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	return func() { p.loopDepth = loopDepth }
+}
+
+// skipEmptySlot skips over a '()', which leaves a slot of a for loop header
+// empty, and reports whether it did.
+func (p *Parser) skipEmptySlot() bool {
+	if !p.expectCur(token.LParen) || !p.expectPeek(token.RParen) {
+		return false
+	}
+	p.nextToken()
+	p.nextToken()
+	return true
+}
+
 func (p *Parser) expectCur(tokType token.Type) bool {
 	return tokType == p.curToken.Type
 }
@@ -613,8 +1012,7 @@ func (p *Parser) expectPeek(tokType token.Type) bool {
 
 func (p *Parser) eatDelimiter() error {
 	if !p.expectCur(token.Delimiter) {
-		return fmt.Errorf("expected token 'DELIMITER' on line %d col %d, but got '%s'",
-			p.curToken.Line, p.curToken.Col, p.curToken.Type)
+		return unexpected(p.curToken, token.Delimiter)
 	}
 	p.nextToken()
 	return nil
@@ -2,10 +2,12 @@ package parser
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/diagnostics"
 	"github.com/avearmin/simple/internal/lexer"
 	"github.com/avearmin/simple/internal/token"
 )
@@ -429,12 +431,16 @@ else (= isBar true))
 						Statements: []ast.Statement{
 							ast.FnCall{
 								Token: token.Token{Type: token.Ident, Literal: "addThenDouble", Line: 23, Col: 5},
-								Arguments: []ast.Atom{
-									{
+								Callee: ast.Atom{
+									Token: token.Token{Type: token.Ident, Literal: "addThenDouble", Line: 23, Col: 5},
+									Value: "addThenDouble",
+								},
+								Arguments: []ast.Expression{
+									ast.Atom{
 										Token: token.Token{Type: token.Ident, Literal: "i", Line: 23, Col: 19},
 										Value: "i",
 									},
-									{
+									ast.Atom{
 										Token: token.Token{Type: token.Int, Literal: "2", Line: 23, Col: 21},
 										Value: "2",
 									},
@@ -445,6 +451,366 @@ else (= isBar true))
 				},
 			},
 		},
+		"nested function calls": {
+			input: "(:= x (add 1 (mul 2 (zero))))",
+			want: &ast.Program{
+				Statements: []ast.Statement{
+					ast.AssignStatement{
+						Token: token.Token{Type: token.Assign, Literal: ":=", Line: 1, Col: 1},
+						Name: ast.Atom{
+							Token: token.Token{Type: token.Ident, Literal: "x", Line: 1, Col: 4},
+							Value: "x",
+						},
+						Value: ast.FnCall{
+							Token: token.Token{Type: token.Ident, Literal: "add", Line: 1, Col: 7},
+							Callee: ast.Atom{
+								Token: token.Token{Type: token.Ident, Literal: "add", Line: 1, Col: 7},
+								Value: "add",
+							},
+							Arguments: []ast.Expression{
+								ast.Atom{
+									Token: token.Token{Type: token.Int, Literal: "1", Line: 1, Col: 11},
+									Value: "1",
+								},
+								ast.FnCall{
+									Token: token.Token{Type: token.Ident, Literal: "mul", Line: 1, Col: 14},
+									Callee: ast.Atom{
+										Token: token.Token{Type: token.Ident, Literal: "mul", Line: 1, Col: 14},
+										Value: "mul",
+									},
+									Arguments: []ast.Expression{
+										ast.Atom{
+											Token: token.Token{Type: token.Int, Literal: "2", Line: 1, Col: 18},
+											Value: "2",
+										},
+										ast.FnCall{
+											Token: token.Token{Type: token.Ident, Literal: "zero", Line: 1, Col: 21},
+											Callee: ast.Atom{
+												Token: token.Token{Type: token.Ident, Literal: "zero", Line: 1, Col: 21},
+												Value: "zero",
+											},
+											Arguments: []ast.Expression{},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"logical and unary expressions": {
+			input: "(:= x (|| a (! b) c))",
+			want: &ast.Program{
+				Statements: []ast.Statement{
+					ast.AssignStatement{
+						Token: token.Token{Type: token.Assign, Literal: ":=", Line: 1, Col: 1},
+						Name: ast.Atom{
+							Token: token.Token{Type: token.Ident, Literal: "x", Line: 1, Col: 4},
+							Value: "x",
+						},
+						Value: ast.LogicalExpression{
+							Token: token.Token{Type: token.Or, Literal: "||", Line: 1, Col: 7},
+							Operands: []ast.Expression{
+								ast.Atom{
+									Token: token.Token{Type: token.Ident, Literal: "a", Line: 1, Col: 10},
+									Value: "a",
+								},
+								ast.UnaryExpression{
+									Token: token.Token{Type: token.Not, Literal: "!", Line: 1, Col: 13},
+									Operand: ast.Atom{
+										Token: token.Token{Type: token.Ident, Literal: "b", Line: 1, Col: 15},
+										Value: "b",
+									},
+								},
+								ast.Atom{
+									Token: token.Token{Type: token.Ident, Literal: "c", Line: 1, Col: 18},
+									Value: "c",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"list literal": {
+			input: "(:= x (list 1 (list)))",
+			want: &ast.Program{
+				Statements: []ast.Statement{
+					ast.AssignStatement{
+						Token: token.Token{Type: token.Assign, Literal: ":=", Line: 1, Col: 1},
+						Name: ast.Atom{
+							Token: token.Token{Type: token.Ident, Literal: "x", Line: 1, Col: 4},
+							Value: "x",
+						},
+						Value: ast.ListLiteral{
+							Token: token.Token{Type: token.List, Literal: "list", Line: 1, Col: 7},
+							Elements: []ast.Expression{
+								ast.Atom{
+									Token: token.Token{Type: token.Int, Literal: "1", Line: 1, Col: 12},
+									Value: "1",
+								},
+								ast.ListLiteral{
+									Token:    token.Token{Type: token.List, Literal: "list", Line: 1, Col: 15},
+									Elements: []ast.Expression{},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"function literal called immediately": {
+			input: "((fn (x) (return x)) 1)",
+			want: &ast.Program{
+				Statements: []ast.Statement{
+					ast.FnCall{
+						Token: token.Token{Type: token.LParen, Literal: "(", Line: 1, Col: 1},
+						Callee: ast.FunctionLiteral{
+							Token: token.Token{Type: token.Fn, Literal: "fn", Line: 1, Col: 2},
+							Params: []ast.Atom{
+								{
+									Token: token.Token{Type: token.Ident, Literal: "x", Line: 1, Col: 6},
+									Value: "x",
+								},
+							},
+							Statements: []ast.Statement{
+								ast.ReturnStatement{
+									Token: token.Token{Type: token.Return, Literal: "return", Line: 1, Col: 10},
+									Value: ast.Atom{
+										Token: token.Token{Type: token.Ident, Literal: "x", Line: 1, Col: 17},
+										Value: "x",
+									},
+								},
+							},
+						},
+						Arguments: []ast.Expression{
+							ast.Atom{
+								Token: token.Token{Type: token.Int, Literal: "1", Line: 1, Col: 21},
+								Value: "1",
+							},
+						},
+					},
+				},
+			},
+		},
+		"for loop with empty slots": {
+			input: "(for () done () (f))",
+			want: &ast.Program{
+				Statements: []ast.Statement{
+					ast.ForLoopStatement{
+						Token: token.Token{Type: token.For, Literal: "for", Line: 1, Col: 1},
+						Condition: ast.Atom{
+							Token: token.Token{Type: token.Ident, Literal: "done", Line: 1, Col: 8},
+							Value: "done",
+						},
+						Statements: []ast.Statement{
+							ast.FnCall{
+								Token: token.Token{Type: token.Ident, Literal: "f", Line: 1, Col: 17},
+								Callee: ast.Atom{
+									Token: token.Token{Type: token.Ident, Literal: "f", Line: 1, Col: 17},
+									Value: "f",
+								},
+								Arguments: []ast.Expression{},
+							},
+						},
+					},
+				},
+			},
+		},
+		"for each with key and value": {
+			input: "(for k v in m (f))",
+			want: &ast.Program{
+				Statements: []ast.Statement{
+					ast.ForEachStatement{
+						Token: token.Token{Type: token.For, Literal: "for", Line: 1, Col: 1},
+						Names: []ast.Atom{
+							{
+								Token: token.Token{Type: token.Ident, Literal: "k", Line: 1, Col: 5},
+								Value: "k",
+							},
+							{
+								Token: token.Token{Type: token.Ident, Literal: "v", Line: 1, Col: 7},
+								Value: "v",
+							},
+						},
+						Iterable: ast.Atom{
+							Token: token.Token{Type: token.Ident, Literal: "m", Line: 1, Col: 12},
+							Value: "m",
+						},
+						Statements: []ast.Statement{
+							ast.FnCall{
+								Token: token.Token{Type: token.Ident, Literal: "f", Line: 1, Col: 15},
+								Callee: ast.Atom{
+									Token: token.Token{Type: token.Ident, Literal: "f", Line: 1, Col: 15},
+									Value: "f",
+								},
+								Arguments: []ast.Expression{},
+							},
+						},
+					},
+				},
+			},
+		},
+		"while with break and continue": {
+			input: "(while x (if y (continue)) (break))",
+			want: &ast.Program{
+				Statements: []ast.Statement{
+					ast.WhileStatement{
+						Token: token.Token{Type: token.While, Literal: "while", Line: 1, Col: 1},
+						Condition: ast.Atom{
+							Token: token.Token{Type: token.Ident, Literal: "x", Line: 1, Col: 7},
+							Value: "x",
+						},
+						Statements: []ast.Statement{
+							ast.ConditionalStatement{
+								Token: token.Token{Type: token.If, Literal: "if", Line: 1, Col: 10},
+								IfCondition: ast.Atom{
+									Token: token.Token{Type: token.Ident, Literal: "y", Line: 1, Col: 13},
+									Value: "y",
+								},
+								IfStatements: []ast.Statement{
+									ast.ContinueStatement{
+										Token: token.Token{Type: token.Continue, Literal: "continue", Line: 1, Col: 16},
+									},
+								},
+							},
+							ast.BreakStatement{
+								Token: token.Token{Type: token.Break, Literal: "break", Line: 1, Col: 28},
+							},
+						},
+					},
+				},
+			},
+		},
+		"if and elif without else": {
+			input: "(if x (f) (g) elif y (h))",
+			want: &ast.Program{
+				Statements: []ast.Statement{
+					ast.ConditionalStatement{
+						Token: token.Token{Type: token.If, Literal: "if", Line: 1, Col: 1},
+						IfCondition: ast.Atom{
+							Token: token.Token{Type: token.Ident, Literal: "x", Line: 1, Col: 4},
+							Value: "x",
+						},
+						IfStatements: []ast.Statement{
+							ast.FnCall{
+								Token: token.Token{Type: token.Ident, Literal: "f", Line: 1, Col: 7},
+								Callee: ast.Atom{
+									Token: token.Token{Type: token.Ident, Literal: "f", Line: 1, Col: 7},
+									Value: "f",
+								},
+								Arguments: []ast.Expression{},
+							},
+							ast.FnCall{
+								Token: token.Token{Type: token.Ident, Literal: "g", Line: 1, Col: 11},
+								Callee: ast.Atom{
+									Token: token.Token{Type: token.Ident, Literal: "g", Line: 1, Col: 11},
+									Value: "g",
+								},
+								Arguments: []ast.Expression{},
+							},
+						},
+						ElifBlocks: []ast.ElifBlock{
+							{
+								Token: token.Token{Type: token.Elif, Literal: "elif", Line: 1, Col: 14},
+								Condition: ast.Atom{
+									Token: token.Token{Type: token.Ident, Literal: "y", Line: 1, Col: 19},
+									Value: "y",
+								},
+								Statements: []ast.Statement{
+									ast.FnCall{
+										Token: token.Token{Type: token.Ident, Literal: "h", Line: 1, Col: 22},
+										Callee: ast.Atom{
+											Token: token.Token{Type: token.Ident, Literal: "h", Line: 1, Col: 22},
+											Value: "h",
+										},
+										Arguments: []ast.Expression{},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"nil": {
+			input: "(:= x (== y nil))",
+			want: &ast.Program{
+				Statements: []ast.Statement{
+					ast.AssignStatement{
+						Token: token.Token{Type: token.Assign, Literal: ":=", Line: 1, Col: 1},
+						Name: ast.Atom{
+							Token: token.Token{Type: token.Ident, Literal: "x", Line: 1, Col: 4},
+							Value: "x",
+						},
+						Value: ast.BinaryExpression{
+							Token: token.Token{Type: token.Equals, Literal: "==", Line: 1, Col: 7},
+							First: ast.Atom{
+								Token: token.Token{Type: token.Ident, Literal: "y", Line: 1, Col: 10},
+								Value: "y",
+							},
+							Second: ast.Atom{
+								Token: token.Token{Type: token.Nil, Literal: "nil", Line: 1, Col: 12},
+								Value: "nil",
+							},
+						},
+					},
+				},
+			},
+		},
+		"function without a body": {
+			input: "(fn f x)\n(g)",
+			want: &ast.Program{
+				Statements: []ast.Statement{
+					ast.FunctionAssignStatement{
+						Token: token.Token{Type: token.Fn, Literal: "fn", Line: 1, Col: 1},
+						Name: ast.Atom{
+							Token: token.Token{Type: token.Ident, Literal: "f", Line: 1, Col: 4},
+							Value: "f",
+						},
+						Params: []ast.Atom{
+							{
+								Token: token.Token{Type: token.Ident, Literal: "x", Line: 1, Col: 6},
+								Value: "x",
+							},
+						},
+					},
+					ast.FnCall{
+						Token: token.Token{Type: token.Ident, Literal: "g", Line: 2, Col: 1},
+						Callee: ast.Atom{
+							Token: token.Token{Type: token.Ident, Literal: "g", Line: 2, Col: 1},
+							Value: "g",
+						},
+						Arguments: []ast.Expression{},
+					},
+				},
+			},
+		},
+		"string": {
+			input: `(print "foo\n" bar)`,
+			want: &ast.Program{
+				Statements: []ast.Statement{
+					ast.FnCall{
+						Token: token.Token{Type: token.Ident, Literal: "print", Line: 1, Col: 1},
+						Callee: ast.Atom{
+							Token: token.Token{Type: token.Ident, Literal: "print", Line: 1, Col: 1},
+							Value: "print",
+						},
+						Arguments: []ast.Expression{
+							ast.Atom{
+								Token: token.Token{Type: token.String, Literal: "foo\n", Line: 1, Col: 7},
+								Value: "foo\n",
+							},
+							ast.Atom{
+								Token: token.Token{Type: token.Ident, Literal: "bar", Line: 1, Col: 15},
+								Value: "bar",
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -471,6 +837,301 @@ else (= isBar true))
 	}
 }
 
+func TestParseBinaryExpressionOperands(t *testing.T) {
+	tests := map[string]struct {
+		input      string
+		wantFirst  ast.Expression
+		wantSecond ast.Expression
+	}{
+		"atoms": {
+			input: "(:= x (* 2 z))",
+			wantFirst: ast.Atom{
+				Token: token.Token{Type: token.Int, Literal: "2", Line: 1, Col: 9},
+				Value: "2",
+			},
+			wantSecond: ast.Atom{
+				Token: token.Token{Type: token.Ident, Literal: "z", Line: 1, Col: 11},
+				Value: "z",
+			},
+		},
+		"call as the second operand": {
+			input: "(:= x (- a (f 1)))",
+			wantFirst: ast.Atom{
+				Token: token.Token{Type: token.Ident, Literal: "a", Line: 1, Col: 9},
+				Value: "a",
+			},
+			wantSecond: ast.FnCall{
+				Token: token.Token{Type: token.Ident, Literal: "f", Line: 1, Col: 12},
+				Callee: ast.Atom{
+					Token: token.Token{Type: token.Ident, Literal: "f", Line: 1, Col: 12},
+					Value: "f",
+				},
+				Arguments: []ast.Expression{
+					ast.Atom{
+						Token: token.Token{Type: token.Int, Literal: "1", Line: 1, Col: 14},
+						Value: "1",
+					},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			program, err := New(lexer.New(test.input)).ParseProgram()
+			if err != nil {
+				t.Fatalf("Parsing failed with error: %s", err.Error())
+			}
+
+			exp := program.Statements[0].(ast.AssignStatement).Value.(ast.BinaryExpression)
+			if !isEqualExpressions(exp.First, test.wantFirst) {
+				t.Fatalf("First: got=%v, want=%v", exp.First, test.wantFirst)
+			}
+			if !isEqualExpressions(exp.Second, test.wantSecond) {
+				t.Fatalf("Second: got=%v, want=%v", exp.Second, test.wantSecond)
+			}
+		})
+	}
+}
+
+func TestParseCommentBeforeClosingParen(t *testing.T) {
+	tests := map[string]string{
+		"function statement": "(fn foo x\n    (return x) ; trailing\n)",
+		"block comment":      "(fn foo x (return x) #| blk |#)",
+		"function literal":   "(:= foo (fn (x) (return x) ; trailing\n))",
+		"for loop":           "(for (:= i 0) (< i 3) (= i (+ i 1))\n    (print i) ; trailing\n)",
+		"for each":           "(for i in xs\n    (print i) ; trailing\n)",
+		"while":              "(while true\n    (break) ; trailing\n)",
+		"if":                 "(if true\n    (print 1) ; trailing\n)",
+		"call arguments":     "(print (+ 1 2) ; trailing\n)",
+		"list elements":      "(:= xs (list 1 2 ; trailing\n))",
+		"logical operands":   "(:= ok (&& true false ; trailing\n))",
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			program, err := New(lexer.New(input)).ParseProgram()
+			if err != nil {
+				t.Fatalf("Parsing failed with error: %s", err.Error())
+			}
+			if len(program.Statements) != 1 {
+				t.Fatalf("expected 1 statement, but got %d", len(program.Statements))
+			}
+			if len(program.Comments) != 1 {
+				t.Fatalf("expected 1 comment, but got %d", len(program.Comments))
+			}
+		})
+	}
+}
+
+func TestParseProgramErrors(t *testing.T) {
+	loopNote := func(keyword string) []string {
+		return []string{fmt.Sprintf("'%s' can only be used in the body of a loop, and not in a function inside one", keyword)}
+	}
+	eofNote := []string{"the input ended before every '(' was closed"}
+
+	tests := map[string]struct {
+		input          string
+		want           diagnostics.List
+		wantStatements int
+	}{
+		"break outside a loop": {
+			input: "(if true (break))",
+			want: diagnostics.List{{
+				Code: diagnostics.OutsideLoop, Span: span(1, 10, 15),
+				Message: "'break' is not inside a loop", Notes: loopNote("break"),
+			}},
+		},
+		"continue outside a loop": {
+			input: "(continue)",
+			want: diagnostics.List{{
+				Code: diagnostics.OutsideLoop, Span: span(1, 1, 9),
+				Message: "'continue' is not inside a loop", Notes: loopNote("continue"),
+			}},
+		},
+		"break in a function inside a loop": {
+			input: "(while true (fn f (break)))",
+			want: diagnostics.List{{
+				Code: diagnostics.OutsideLoop, Span: span(1, 19, 24),
+				Message: "'break' is not inside a loop", Notes: loopNote("break"),
+			}},
+		},
+		"for each with three names": {
+			input: "(for a b c in xs (f))",
+			want: diagnostics.List{{
+				Code: diagnostics.UnexpectedToken, Span: span(1, 9, 10),
+				Message:  "expected 'IN', but got 'IDENT'",
+				Expected: []token.Type{token.In}, Found: token.Ident,
+			}},
+		},
+		"break with an argument": {
+			input: "(while true (break 1))",
+			want: diagnostics.List{{
+				Code: diagnostics.UnexpectedToken, Span: span(1, 18, 18),
+				Message:  "expected ')', but got 'DELIMITER'",
+				Expected: []token.Type{token.RParen}, Found: token.Delimiter,
+			}},
+		},
+		"missing closing parenthesis": {
+			input: "(:= x 1",
+			want: diagnostics.List{{
+				Code: diagnostics.UnexpectedToken, Span: span(1, 6, 6),
+				Message: "expected ')', but got 'EOF'", Notes: eofNote,
+				Fix:      &diagnostics.Fix{Message: "insert ')'", Span: span(1, 6, 6), Replacement: ")"},
+				Expected: []token.Type{token.RParen}, Found: token.EOF,
+			}},
+		},
+		"invalid number": {
+			input: "(:= x 1.2.3)",
+			want: diagnostics.List{{
+				Code: diagnostics.InvalidLiteral, Span: span(1, 6, 11),
+				Message:  "invalid number '1.2.3'",
+				Expected: expressionStarts, Found: token.Illegal,
+			}},
+		},
+		"unterminated string": {
+			input: `(print "abc`,
+			want: diagnostics.List{{
+				Code: diagnostics.UnterminatedString, Span: span(1, 7, 11),
+				Message:  "string is not terminated",
+				Fix:      &diagnostics.Fix{Message: `insert '"'`, Span: span(1, 11, 11), Replacement: `"`},
+				Expected: expressionStarts, Found: token.Illegal,
+			}},
+		},
+		"invalid escape sequence": {
+			input: `(print "a\qb")`,
+			want: diagnostics.List{{
+				Code: diagnostics.InvalidEscape, Span: span(1, 9, 11),
+				Message:  `invalid escape sequence '\q'`,
+				Notes:    []string{`the valid escape sequences are \n, \t, \", \\ and \u{...} with 1 to 6 hex digits`},
+				Expected: expressionStarts, Found: token.Illegal,
+			}},
+		},
+		"recovery after each statement": {
+			input: "(:= x)\n(:= y 1)\n(= 1 y)\n(print y",
+			want: diagnostics.List{
+				{
+					Code: diagnostics.UnexpectedToken, Span: span(1, 5, 6),
+					Message:  "expected 'DELIMITER', but got ')'",
+					Expected: []token.Type{token.Delimiter}, Found: token.RParen,
+				},
+				{
+					Code: diagnostics.UnexpectedToken, Span: span(3, 3, 4),
+					Message:  "expected 'IDENT', but got 'INT'",
+					Expected: []token.Type{token.Ident}, Found: token.Int,
+				},
+				{
+					Code: diagnostics.UnexpectedToken, Span: span(4, 7, 7),
+					Message: "expected 'DELIMITER', but got 'EOF'", Notes: eofNote,
+					Expected: []token.Type{token.Delimiter}, Found: token.EOF,
+				},
+			},
+			wantStatements: 1,
+		},
+		"recovery inside nested parentheses": {
+			input: "(if true (:= 1 2) else (= x 2))\n(:= y 1)",
+			want: diagnostics.List{{
+				Code: diagnostics.UnexpectedToken, Span: span(1, 13, 14),
+				Message:  "expected 'IDENT', but got 'INT'",
+				Expected: []token.Type{token.Ident}, Found: token.Int,
+			}},
+			wantStatements: 1,
+		},
+		"binary expression with one operand": {
+			input: "(:= x (+ 1))\n(:= y 2)",
+			want: diagnostics.List{{
+				Code: diagnostics.UnexpectedToken, Span: span(1, 10, 11),
+				Message:  "expected 'DELIMITER', but got ')'",
+				Expected: []token.Type{token.Delimiter}, Found: token.RParen,
+			}},
+			wantStatements: 1,
+		},
+		"stray closing parenthesis": {
+			input: ") (:= x 1)",
+			want: diagnostics.List{{
+				Code: diagnostics.UnexpectedToken, Span: span(1, 0, 1),
+				Message:  "expected '(', but got ')'",
+				Expected: []token.Type{token.LParen}, Found: token.RParen,
+			}},
+			wantStatements: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			program, err := New(lexer.New(test.input)).ParseProgram()
+			if err == nil {
+				t.Fatalf("expected errors %q, but parsing succeeded", test.want)
+			}
+
+			got, ok := err.(diagnostics.List)
+			if !ok {
+				t.Fatalf("expected a diagnostics.List, but got %T", err)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got=%q, want=%q", got, test.want)
+			}
+			for i := range got {
+				if !reflect.DeepEqual(got[i], test.want[i]) {
+					t.Fatalf("%d: got=%+v, want=%+v", i, got[i], test.want[i])
+				}
+			}
+
+			if len(program.Statements) != test.wantStatements {
+				t.Fatalf("expected %d statements to parse, but got %d", test.wantStatements, len(program.Statements))
+			}
+		})
+	}
+}
+
+func TestSpans(t *testing.T) {
+	input := "(:= x (+ 1 2))\n(for i in (range 3)\n  (break))\n(f (fn (a) (return a)))"
+
+	program, err := New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assign := program.Statements[0].(ast.AssignStatement)
+	forEach := program.Statements[1].(ast.ForEachStatement)
+	call := program.Statements[2].(ast.FnCall)
+
+	tests := map[string]struct {
+		input ast.Node
+		want  string
+	}{
+		"statement":            {input: assign, want: "(:= x (+ 1 2))"},
+		"atom":                 {input: assign.Name, want: "x"},
+		"binary expression":    {input: assign.Value, want: "(+ 1 2)"},
+		"statement over lines": {input: forEach, want: "(for i in (range 3)\n  (break))"},
+		"call in a header":     {input: forEach.Iterable, want: "(range 3)"},
+		"break":                {input: forEach.Statements[0], want: "(break)"},
+		"call":                 {input: call, want: "(f (fn (a) (return a)))"},
+		"function literal":     {input: call.Arguments[0], want: "(fn (a) (return a))"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := test.input.Span()
+			if got := input[s.Start.Offset:s.End.Offset]; got != test.want {
+				t.Fatalf("got=%q, want=%q", got, test.want)
+			}
+		})
+	}
+
+	if s := forEach.Span(); s.Start.Line != 2 || s.Start.Col != 0 || s.End.Line != 3 || s.End.Col != 10 {
+		t.Fatalf("expected the for loop to span 2:0 to 3:10, but got %+v", s)
+	}
+}
+
+// span returns the span of the columns from start up to end of a line.
+func span(line, start, end int) diagnostics.Span {
+	return diagnostics.Span{
+		Start: diagnostics.Position{Line: line, Col: start},
+		End:   diagnostics.Position{Line: line, Col: end},
+	}
+}
+
 func isEqualPrograms(first, second *ast.Program) bool {
 	if len(first.Statements) != len(second.Statements) {
 		return false
@@ -529,6 +1190,30 @@ func isEqualStatements(first, second ast.Statement) bool {
 			return false
 		}
 		return isEqualForLoopStatements(stmtOne, stmtTwo)
+	case ast.ForEachStatement:
+		stmtTwo, ok := second.(ast.ForEachStatement)
+		if !ok {
+			return false
+		}
+		return isEqualForEachStatements(stmtOne, stmtTwo)
+	case ast.WhileStatement:
+		stmtTwo, ok := second.(ast.WhileStatement)
+		if !ok {
+			return false
+		}
+		return isEqualWhileStatements(stmtOne, stmtTwo)
+	case ast.BreakStatement:
+		stmtTwo, ok := second.(ast.BreakStatement)
+		if !ok {
+			return false
+		}
+		return isEqualTokens(stmtOne.Token, stmtTwo.Token)
+	case ast.ContinueStatement:
+		stmtTwo, ok := second.(ast.ContinueStatement)
+		if !ok {
+			return false
+		}
+		return isEqualTokens(stmtOne.Token, stmtTwo.Token)
 	}
 	return false
 }
@@ -547,6 +1232,49 @@ func isEqualExpressions(first, second ast.Expression) bool {
 			return false
 		}
 		return isEqualAtoms(expOne, expTwo)
+	case ast.FnCall:
+		expTwo, ok := second.(ast.FnCall)
+		if !ok {
+			return false
+		}
+		return isEqualFnCalls(expOne, expTwo)
+	case ast.LogicalExpression:
+		expTwo, ok := second.(ast.LogicalExpression)
+		if !ok || !isEqualTokens(expOne.Token, expTwo.Token) || len(expOne.Operands) != len(expTwo.Operands) {
+			return false
+		}
+		for i := range expOne.Operands {
+			if !isEqualExpressions(expOne.Operands[i], expTwo.Operands[i]) {
+				return false
+			}
+		}
+		return true
+	case ast.FunctionLiteral:
+		expTwo, ok := second.(ast.FunctionLiteral)
+		if !ok {
+			return false
+		}
+		return isEqualFunctionAssignStatements(
+			ast.FunctionAssignStatement{Token: expOne.Token, Params: expOne.Params, Statements: expOne.Statements},
+			ast.FunctionAssignStatement{Token: expTwo.Token, Params: expTwo.Params, Statements: expTwo.Statements},
+		)
+	case ast.ListLiteral:
+		expTwo, ok := second.(ast.ListLiteral)
+		if !ok || !isEqualTokens(expOne.Token, expTwo.Token) || len(expOne.Elements) != len(expTwo.Elements) {
+			return false
+		}
+		for i := range expOne.Elements {
+			if !isEqualExpressions(expOne.Elements[i], expTwo.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case ast.UnaryExpression:
+		expTwo, ok := second.(ast.UnaryExpression)
+		if !ok {
+			return false
+		}
+		return isEqualTokens(expOne.Token, expTwo.Token) && isEqualExpressions(expOne.Operand, expTwo.Operand)
 	}
 
 	return false
@@ -613,6 +1341,16 @@ func isEqualFunctionAssignStatements(first, second ast.FunctionAssignStatement)
 		return false
 	}
 
+	if len(first.Params) != len(second.Params) {
+		return false
+	}
+
+	for i := range first.Params {
+		if !isEqualAtoms(first.Params[i], second.Params[i]) {
+			return false
+		}
+	}
+
 	if len(first.Statements) != len(second.Statements) {
 		return false
 	}
@@ -639,7 +1377,7 @@ func isEqualReturnStatements(first, second ast.ReturnStatement) bool {
 }
 
 func isEqualFnCalls(first, second ast.FnCall) bool {
-	if !isEqualTokens(first.Token, second.Token) {
+	if !isEqualTokens(first.Token, second.Token) || !isEqualExpressions(first.Callee, second.Callee) {
 		return false
 	}
 
@@ -648,7 +1386,7 @@ func isEqualFnCalls(first, second ast.FnCall) bool {
 	}
 
 	for i := range first.Arguments {
-		if !isEqualAtoms(first.Arguments[i], second.Arguments[i]) {
+		if !isEqualExpressions(first.Arguments[i], second.Arguments[i]) {
 			return false
 		}
 	}
@@ -661,15 +1399,81 @@ func isEqualForLoopStatements(first, second ast.ForLoopStatement) bool {
 		return false
 	}
 
-	if !isEqualAssignStatement(first.Initalizer, second.Initalizer) {
+	if !isEqualOptionalStatements(first.Initalizer, second.Initalizer) {
+		return false
+	}
+
+	if (first.Condition == nil) != (second.Condition == nil) {
+		return false
+	}
+	if first.Condition != nil && !isEqualExpressions(first.Condition, second.Condition) {
+		return false
+	}
+
+	if !isEqualOptionalStatements(first.Update, second.Update) {
+		return false
+	}
+
+	if len(first.Statements) != len(second.Statements) {
+		return false
+	}
+
+	for i := range first.Statements {
+		if !isEqualStatements(first.Statements[i], second.Statements[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isEqualForEachStatements(first, second ast.ForEachStatement) bool {
+	if !isEqualTokens(first.Token, second.Token) {
+		return false
+	}
+
+	if len(first.Names) != len(second.Names) {
+		return false
+	}
+
+	for i := range first.Names {
+		if !isEqualAtoms(first.Names[i], second.Names[i]) {
+			return false
+		}
+	}
+
+	if !isEqualExpressions(first.Iterable, second.Iterable) {
 		return false
 	}
 
-	if !isEqualBinaryExpressions(first.Condition, second.Condition) {
+	if len(first.Statements) != len(second.Statements) {
 		return false
 	}
 
-	if !isEqualReassignStatement(first.Update, second.Update) {
+	for i := range first.Statements {
+		if !isEqualStatements(first.Statements[i], second.Statements[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isEqualOptionalStatements is isEqualStatements for statements that may be
+// left out.
+func isEqualOptionalStatements(first, second ast.Statement) bool {
+	if first == nil || second == nil {
+		return first == nil && second == nil
+	}
+	return isEqualStatements(first, second)
+}
+
+func isEqualWhileStatements(first, second ast.WhileStatement) bool {
+	if !isEqualTokens(first.Token, second.Token) {
+		return false
+	}
+
+	if !isEqualExpressions(first.Condition, second.Condition) {
 		return false
 	}
 
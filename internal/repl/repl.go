@@ -0,0 +1,196 @@
+// Package repl implements an interactive session that evaluates statements
+// as they are typed.
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/diagnostics"
+	"github.com/avearmin/simple/internal/evaluator"
+	"github.com/avearmin/simple/internal/lexer"
+	"github.com/avearmin/simple/internal/object"
+	"github.com/avearmin/simple/internal/parser"
+	"github.com/avearmin/simple/internal/token"
+)
+
+const (
+	Prompt             = ">> "
+	ContinuationPrompt = ".. "
+)
+
+// inputName stands in for a filename in the diagnostics of an input.
+const inputName = "<input>"
+
+const help = `Enter statements to evaluate them. An input is read until its
+parentheses are balanced, so a statement may span several lines.
+
+commands:
+  :help     show this message
+  :env      list the bindings of the session
+  :ast      show the parsed form of the last input
+  :history  list the inputs of the session
+  :reset    forget every binding
+  :quit     end the session
+`
+
+// REPL holds the state of a session. Bindings made by one input are visible
+// to every input after it.
+type REPL struct {
+	out io.Writer
+
+	env         *object.Environment
+	history     []string
+	lastProgram *ast.Program
+}
+
+func New(out io.Writer) *REPL {
+	return &REPL{out: out, env: object.NewEnvironment(out)}
+}
+
+// Start runs a session, reading inputs from in until it is exhausted or the
+// :quit command is given.
+func Start(in io.Reader, out io.Writer) error {
+	return New(out).Run(in)
+}
+
+func (r *REPL) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+
+	var input strings.Builder
+	for {
+		if input.Len() == 0 {
+			fmt.Fprint(r.out, Prompt)
+		} else {
+			fmt.Fprint(r.out, ContinuationPrompt)
+		}
+
+		if !scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+		line := scanner.Text()
+
+		if input.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if quit := r.command(strings.TrimSpace(line)); quit {
+				return nil
+			}
+			continue
+		}
+
+		input.WriteString(line)
+		input.WriteString("\n")
+
+		depth := nesting(input.String())
+		if depth > 0 {
+			continue
+		}
+
+		source := input.String()
+		input.Reset()
+
+		if depth < 0 {
+			fmt.Fprintln(r.out, "unexpected ')'")
+			continue
+		}
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+
+		r.history = append(r.history, strings.TrimRight(source, "\n"))
+		r.eval(source)
+	}
+}
+
+// command runs a session command. It reports whether the session should end.
+func (r *REPL) command(name string) bool {
+	switch name {
+	case ":help":
+		fmt.Fprint(r.out, help)
+	case ":env":
+		for _, name := range r.env.Names() {
+			value, _ := r.env.Get(name)
+			fmt.Fprintf(r.out, "%s = %s\n", name, value.Inspect())
+		}
+	case ":ast":
+		if r.lastProgram == nil {
+			fmt.Fprintln(r.out, "no input has been parsed yet")
+			return false
+		}
+		ast.Fprint(r.out, r.lastProgram)
+	case ":history":
+		for i, input := range r.history {
+			fmt.Fprintf(r.out, "%d  %s\n", i+1, input)
+		}
+	case ":reset":
+		r.env = object.NewEnvironment(r.out)
+		r.lastProgram = nil
+	case ":quit":
+		return true
+	default:
+		fmt.Fprintf(r.out, "unknown command '%s', see :help\n", name)
+	}
+
+	return false
+}
+
+// eval parses source and evaluates its statements one at a time, printing
+// the value of each one. Nil values are not printed.
+func (r *REPL) eval(source string) {
+	program, err := parser.New(lexer.New(source)).ParseProgram()
+	if err != nil {
+		r.report(source, err)
+		return
+	}
+	r.lastProgram = program
+
+	for _, stmt := range program.Statements {
+		result, err := evaluator.Eval(&ast.Program{Statements: []ast.Statement{stmt}}, r.env)
+		if err != nil {
+			fmt.Fprintln(r.out, err)
+			return
+		}
+
+		if _, ok := result.(object.Nil); !ok {
+			fmt.Fprintln(r.out, result.Inspect())
+		}
+	}
+}
+
+// report renders the diagnostics in err the way the command line does, or
+// prints err if it is not a list of diagnostics.
+func (r *REPL) report(source string, err error) {
+	diags, ok := err.(diagnostics.List)
+	if !ok {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+
+	for _, d := range diags {
+		diagnostics.Render(r.out, inputName, source, d)
+	}
+}
+
+// nesting returns the number of parentheses left open at the end of source.
+// It is negative if a closing parenthesis has no opening one.
+func nesting(source string) int {
+	depth := 0
+
+	l := lexer.New(source)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LParen:
+			depth++
+		case token.RParen:
+			depth--
+			if depth < 0 {
+				return depth
+			}
+		}
+	}
+
+	return depth
+}
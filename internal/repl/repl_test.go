@@ -0,0 +1,110 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  []string
+	}{
+		"values are printed": {
+			input: "(:= x 5)\n(= x (+ x 1))\n",
+			want:  []string{"5", "6"},
+		},
+		"nil values are not printed": {
+			input: "(if true (:= x 1))\n(:= y 2)\n",
+			want:  []string{"2"},
+		},
+		"output of print": {
+			input: "(print \"hello\")\n",
+			want:  []string{"hello"},
+		},
+		"bindings persist between inputs": {
+			input: "(fn add a b (return (+ a b)))\n(:= x 2)\n(:= y (+ x x))\n",
+			want:  []string{"fn add(a, b)", "2", "4"},
+		},
+		"unbalanced input continues on the next line": {
+			input: "(:= x\n  (+ 1\n     2))\n",
+			want:  []string{"3"},
+		},
+		"unexpected closing paren": {
+			input: ")\n(:= x 1)\n",
+			want:  []string{"unexpected ')'", "1"},
+		},
+		"parse errors are rendered": {
+			input: "(:= x)\n(:= x 1)\n",
+			want: []string{
+				"error[P001]: expected 'DELIMITER', but got ')'",
+				" --> <input>:1:5",
+				"  |",
+				"1 | (:= x)",
+				"  |      ^",
+				"1",
+			},
+		},
+		"errors do not end the session": {
+			input: "(= x 1)\n(:= x 1)\n",
+			want:  []string{"cannot reassign 'x' before it is assigned on line 1 col 3", "1"},
+		},
+		"env": {
+			input: "(:= b true)\n(:= a 1)\n:env\n",
+			want:  []string{"true", "1", "a = 1", "b = true"},
+		},
+		"reset": {
+			input: "(:= a 1)\n:reset\n:env\n(:= b a)\n",
+			want:  []string{"1", "undefined identifier 'a' on line 1 col 6"},
+		},
+		"ast": {
+			input: "(:= a 1)\n:ast\n",
+			want: []string{
+				"1",
+				"Program",
+				"  Statements:",
+				`    AssignStatement := ":=" 1:1`,
+				`      Name: Atom IDENT "a" 1:4`,
+				`      Value: Atom INT "1" 1:6`,
+			},
+		},
+		"history": {
+			input: "(:= a\n 1)\n(:= b 2)\n:history\n",
+			want:  []string{"1", "2", "1  (:= a", " 1)", "2  (:= b 2)"},
+		},
+		"quit": {
+			input: "(:= a 1)\n:quit\n(:= b 2)\n",
+			want:  []string{"1"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Start(strings.NewReader(test.input), &out); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			got := output(out.String())
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Fatalf("got=%q, but want=%q", got, test.want)
+			}
+		})
+	}
+}
+
+// output strips the prompts from a session's output and returns the lines
+// that are left.
+func output(s string) []string {
+	s = strings.ReplaceAll(s, Prompt, "")
+	s = strings.ReplaceAll(s, ContinuationPrompt, "")
+
+	lines := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
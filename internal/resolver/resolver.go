@@ -0,0 +1,295 @@
+// Package resolver binds each name a program uses to the declaration it
+// refers to, and reports names that are used without being declared before
+// the program runs.
+package resolver
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/diagnostics"
+	"github.com/avearmin/simple/internal/object"
+	"github.com/avearmin/simple/internal/token"
+)
+
+// Kind is the way a name was declared.
+type Kind int
+
+const (
+	Variable Kind = iota
+	Function
+	Parameter
+	LoopVariable
+	Builtin
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Variable:
+		return "variable"
+	case Function:
+		return "function"
+	case Parameter:
+		return "parameter"
+	case LoopVariable:
+		return "loop variable"
+	case Builtin:
+		return "builtin"
+	default:
+		return fmt.Sprintf("kind(%d)", int(k))
+	}
+}
+
+// Declaration is the place a name is declared. Name is the Atom that
+// declares it, and Node the AssignStatement, FunctionAssignStatement,
+// FunctionLiteral or ForEachStatement it belongs to. Builtins are not
+// declared in the source, so only the Value of their Name is set, and their
+// Node is nil.
+type Declaration struct {
+	Kind Kind
+	Name ast.Atom
+	Node ast.Node
+}
+
+// Bindings maps the position of each identifier a program uses, including
+// the names of ReassignStatements, to its declaration.
+type Bindings map[token.Position]*Declaration
+
+// Of returns the declaration the identifier atom refers to.
+func (b Bindings) Of(atom ast.Atom) (*Declaration, bool) {
+	decl, ok := b[atom.Token.Pos()]
+	return decl, ok
+}
+
+// Resolve binds the names used in program. Function bodies are resolved
+// once the body or program they appear in has been, so they can refer to
+// names declared after them, as they are only looked up when the function
+// is called.
+//
+// If any name cannot be resolved, the error is a diagnostics.List of every
+// problem found, in the order they appear in the source, and the bindings
+// hold the names that could be.
+func Resolve(program *ast.Program) (Bindings, error) {
+	r := &resolver{bindings: Bindings{}, scope: newScope(universe())}
+
+	r.body(program.Statements)
+
+	if len(r.diags) > 0 {
+		sort.SliceStable(r.diags, func(i, j int) bool {
+			a, b := r.diags[i].Span.Start, r.diags[j].Span.Start
+			return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
+		})
+		return r.bindings, r.diags
+	}
+	return r.bindings, nil
+}
+
+// universe is the scope of the builtins, which encloses the program.
+func universe() *scope {
+	s := newScope(nil)
+	for _, def := range object.Builtins {
+		name := ast.Atom{Token: token.Token{Type: token.Ident, Literal: def.Name}, Value: def.Name}
+		s.names[def.Name] = &Declaration{Kind: Builtin, Name: name}
+	}
+	return s
+}
+
+type scope struct {
+	outer *scope
+	names map[string]*Declaration
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, names: map[string]*Declaration{}}
+}
+
+// lookup finds the nearest declaration of name.
+func (s *scope) lookup(name string) (*Declaration, bool) {
+	for ; s != nil; s = s.outer {
+		if decl, ok := s.names[name]; ok {
+			return decl, true
+		}
+	}
+	return nil, false
+}
+
+// function is a function whose body has yet to be resolved, with the scope
+// it was declared in.
+type function struct {
+	scope      *scope
+	node       ast.Node
+	params     []ast.Atom
+	statements []ast.Statement
+}
+
+type resolver struct {
+	scope    *scope
+	bindings Bindings
+	pending  []function
+	diags    diagnostics.List
+}
+
+// body resolves the statements of the program or of a function body, and
+// then the functions declared in them.
+func (r *resolver) body(stmts []ast.Statement) {
+	start := len(r.pending)
+	r.statements(stmts)
+
+	functions := append([]function(nil), r.pending[start:]...)
+	r.pending = r.pending[:start]
+	for _, fn := range functions {
+		r.function(fn)
+	}
+}
+
+func (r *resolver) function(fn function) {
+	outer := r.scope
+	r.scope = newScope(fn.scope)
+	defer func() { r.scope = outer }()
+
+	for _, param := range fn.params {
+		if first, ok := r.scope.names[param.Value]; ok {
+			r.diags = append(r.diags, diagnostics.New(diagnostics.DuplicateParameter, diagnostics.TokenSpan(param.Token),
+				"duplicate parameter '%s'", param.Value).
+				Note("'%s' is first declared on line %d col %d", param.Value, first.Name.Token.Line, first.Name.Token.Col))
+			continue
+		}
+		r.declare(Parameter, param, fn.node)
+	}
+
+	r.body(fn.statements)
+}
+
+// block resolves statements in a scope of their own.
+func (r *resolver) block(stmts []ast.Statement) {
+	r.enter()
+	defer r.leave()
+
+	r.statements(stmts)
+}
+
+func (r *resolver) enter() {
+	r.scope = newScope(r.scope)
+}
+
+func (r *resolver) leave() {
+	r.scope = r.scope.outer
+}
+
+func (r *resolver) declare(kind Kind, name ast.Atom, node ast.Node) {
+	r.scope.names[name.Value] = &Declaration{Kind: kind, Name: name, Node: node}
+}
+
+func (r *resolver) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		r.statement(stmt)
+	}
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case ast.AssignStatement:
+		r.expression(stmt.Value)
+		r.declare(Variable, stmt.Name, stmt)
+	case ast.ReassignStatement:
+		r.expression(stmt.Value)
+		r.reassign(stmt)
+	case ast.ConditionalStatement:
+		r.expression(stmt.IfCondition)
+		r.block(stmt.IfStatements)
+		for _, elif := range stmt.ElifBlocks {
+			r.expression(elif.Condition)
+			r.block(elif.Statements)
+		}
+		r.block(stmt.ElseBlock.Statements)
+	case ast.FunctionAssignStatement:
+		r.declare(Function, stmt.Name, stmt)
+		r.pending = append(r.pending, function{scope: r.scope, node: stmt, params: stmt.Params, statements: stmt.Statements})
+	case ast.ReturnStatement:
+		r.expression(stmt.Value)
+	case ast.ForLoopStatement:
+		r.enter()
+		if stmt.Initalizer != nil {
+			r.statement(stmt.Initalizer)
+		}
+		r.expression(stmt.Condition)
+		r.block(stmt.Statements)
+		if stmt.Update != nil {
+			r.statement(stmt.Update)
+		}
+		r.leave()
+	case ast.ForEachStatement:
+		r.expression(stmt.Iterable)
+		r.enter()
+		for _, name := range stmt.Names {
+			r.declare(LoopVariable, name, stmt)
+		}
+		r.block(stmt.Statements)
+		r.leave()
+	case ast.WhileStatement:
+		r.expression(stmt.Condition)
+		r.block(stmt.Statements)
+	case ast.FnCall:
+		r.expression(stmt)
+	}
+}
+
+// reassign binds the name of stmt to the variable it changes. Builtins
+// cannot be reassigned, so they are passed over.
+func (r *resolver) reassign(stmt ast.ReassignStatement) {
+	name := stmt.Name
+	decl, ok := r.scope.lookup(name.Value)
+	if ok && decl.Kind != Builtin {
+		r.bindings[name.Token.Pos()] = decl
+		return
+	}
+
+	d := diagnostics.New(diagnostics.UndeclaredReassign, diagnostics.TokenSpan(name.Token),
+		"cannot reassign '%s' before it is assigned", name.Value)
+	if ok {
+		d.Note("'%s' is a builtin", name.Value)
+	} else {
+		d.Fix = &diagnostics.Fix{
+			Message:     "replace '=' with ':='",
+			Span:        diagnostics.TokenSpan(stmt.Token),
+			Replacement: token.Assign,
+		}
+	}
+	r.diags = append(r.diags, d)
+}
+
+func (r *resolver) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case ast.Atom:
+		if exp.Token.Type != token.Ident {
+			return
+		}
+		if decl, ok := r.scope.lookup(exp.Value); ok {
+			r.bindings[exp.Token.Pos()] = decl
+			return
+		}
+		r.diags = append(r.diags, diagnostics.New(diagnostics.UndefinedName, diagnostics.TokenSpan(exp.Token),
+			"undefined identifier '%s'", exp.Value))
+	case ast.BinaryExpression:
+		r.expression(exp.First)
+		r.expression(exp.Second)
+	case ast.FunctionLiteral:
+		r.pending = append(r.pending, function{scope: r.scope, node: exp, params: exp.Params, statements: exp.Statements})
+	case ast.ListLiteral:
+		r.expressions(exp.Elements)
+	case ast.LogicalExpression:
+		r.expressions(exp.Operands)
+	case ast.UnaryExpression:
+		r.expression(exp.Operand)
+	case ast.FnCall:
+		r.expression(exp.Callee)
+		r.expressions(exp.Arguments)
+	}
+}
+
+func (r *resolver) expressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		r.expression(exp)
+	}
+}
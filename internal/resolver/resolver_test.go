@@ -0,0 +1,174 @@
+package resolver
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/diagnostics"
+	"github.com/avearmin/simple/internal/lexer"
+	"github.com/avearmin/simple/internal/parser"
+)
+
+func TestResolve(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  []string
+	}{
+		"assignment": {
+			input: "(:= x 1)\n(print x)",
+			want:  []string{"print 2:1 -> builtin", "x 2:7 -> variable 1:4"},
+		},
+		"value is resolved before the name": {
+			input: "(:= x 1)\n(:= x (+ x 1))\n(= x 2)",
+			want:  []string{"x 2:9 -> variable 1:4", "x 3:3 -> variable 2:4"},
+		},
+		"block shadows the program": {
+			input: "(:= x 1)\n(if x (:= x 2) (= x 3) else (= x 4))",
+			want:  []string{"x 2:4 -> variable 1:4", "x 2:18 -> variable 2:10", "x 2:31 -> variable 1:4"},
+		},
+		"recursion": {
+			input: "(fn f n (return (f (- n 1))))",
+			want:  []string{"f 1:17 -> function 1:4", "n 1:22 -> parameter 1:6"},
+		},
+		"function body refers to a later name": {
+			input: "(fn f (return y))\n(:= y 1)",
+			want:  []string{"y 1:14 -> variable 2:4"},
+		},
+		"closure": {
+			input: "(:= n 0)\n(:= inc (fn () (= n (+ n 1))))",
+			want:  []string{"n 2:18 -> variable 1:4", "n 2:23 -> variable 1:4"},
+		},
+		"for loop": {
+			input: "(for (:= i 0) (< i 3) (= i (+ i 1)) (:= i 5))",
+			want:  []string{"i 1:17 -> variable 1:9", "i 1:25 -> variable 1:9", "i 1:30 -> variable 1:9"},
+		},
+		"for each": {
+			input: "(:= m (list))\n(for k v in m (print k v))",
+			want: []string{
+				"m 2:12 -> variable 1:4", "print 2:15 -> builtin",
+				"k 2:21 -> loop variable 2:5", "v 2:23 -> loop variable 2:7",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bindings, err := Resolve(parse(t, test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := describe(bindings)
+			sort.Strings(got)
+			sort.Strings(test.want)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got=%q, want=%q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  diagnostics.List
+	}{
+		"undefined name": {
+			input: "(print y)",
+			want: diagnostics.List{{
+				Code: diagnostics.UndefinedName, Span: span(1, 7, 8), Message: "undefined identifier 'y'",
+			}},
+		},
+		"used before it is assigned": {
+			input: "(print x)\n(:= x 1)",
+			want: diagnostics.List{{
+				Code: diagnostics.UndefinedName, Span: span(1, 7, 8), Message: "undefined identifier 'x'",
+			}},
+		},
+		"used outside its block": {
+			input: "(while true (:= x 1))\n(print x)",
+			want: diagnostics.List{{
+				Code: diagnostics.UndefinedName, Span: span(2, 7, 8), Message: "undefined identifier 'x'",
+			}},
+		},
+		"reassign undeclared name": {
+			input: "(= x 1)",
+			want: diagnostics.List{{
+				Code: diagnostics.UndeclaredReassign, Span: span(1, 3, 4),
+				Message: "cannot reassign 'x' before it is assigned",
+				Fix:     &diagnostics.Fix{Message: "replace '=' with ':='", Span: span(1, 1, 2), Replacement: ":="},
+			}},
+		},
+		"reassign builtin": {
+			input: "(= print 1)",
+			want: diagnostics.List{{
+				Code: diagnostics.UndeclaredReassign, Span: span(1, 3, 8),
+				Message: "cannot reassign 'print' before it is assigned", Notes: []string{"'print' is a builtin"},
+			}},
+		},
+		"duplicate parameters": {
+			input: "(fn f x x (return x))\n(:= g (fn (a b a)))",
+			want: diagnostics.List{
+				{
+					Code: diagnostics.DuplicateParameter, Span: span(1, 8, 9),
+					Message: "duplicate parameter 'x'", Notes: []string{"'x' is first declared on line 1 col 6"},
+				},
+				{
+					Code: diagnostics.DuplicateParameter, Span: span(2, 15, 16),
+					Message: "duplicate parameter 'a'", Notes: []string{"'a' is first declared on line 2 col 11"},
+				},
+			},
+		},
+		"errors are in source order": {
+			input: "(fn f (return a))\n(print b)",
+			want: diagnostics.List{
+				{Code: diagnostics.UndefinedName, Span: span(1, 14, 15), Message: "undefined identifier 'a'"},
+				{Code: diagnostics.UndefinedName, Span: span(2, 7, 8), Message: "undefined identifier 'b'"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Resolve(parse(t, test.input))
+			if !reflect.DeepEqual(err, test.want) {
+				t.Fatalf("got=%v, want=%v", err, test.want)
+			}
+		})
+	}
+}
+
+// describe lists the bindings as "name line:col -> kind line:col", leaving
+// out the position of builtins.
+func describe(bindings Bindings) []string {
+	described := make([]string, 0, len(bindings))
+	for pos, decl := range bindings {
+		s := fmt.Sprintf("%s %d:%d -> %s", decl.Name.Value, pos.Line, pos.Col, decl.Kind)
+		if decl.Kind != Builtin {
+			s += fmt.Sprintf(" %d:%d", decl.Name.Token.Line, decl.Name.Token.Col)
+		}
+		described = append(described, s)
+	}
+	return described
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	program, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+// span returns the span of the columns from start up to end of a line.
+func span(line, start, end int) diagnostics.Span {
+	return diagnostics.Span{
+		Start: diagnostics.Position{Line: line, Col: start},
+		End:   diagnostics.Position{Line: line, Col: end},
+	}
+}
@@ -11,8 +11,10 @@ const (
 	LParen = "("
 	RParen = ")"
 
-	Int  = "INT"
-	Bool = "BOOL"
+	Int    = "INT"
+	Float  = "FLOAT"
+	Bool   = "BOOL"
+	String = "STRING"
 
 	Assign   = ":="
 	Reassign = "="
@@ -41,7 +43,13 @@ const (
 	Fn     = "FN"
 	Return = "RETURN"
 
-	For = "FOR"
+	For      = "FOR"
+	In       = "IN"
+	While    = "WHILE"
+	Break    = "BREAK"
+	Continue = "CONTINUE"
+
+	List = "LIST"
 
 	Nil = "NIL"
 
@@ -49,13 +57,18 @@ const (
 )
 
 var identToType = map[string]Type{
-	"if":     If,
-	"elif":   Elif,
-	"else":   Else,
-	"fn":     Fn,
-	"return": Return,
-	"for":    For,
-	"nil":    Nil,
+	"if":       If,
+	"elif":     Elif,
+	"else":     Else,
+	"fn":       Fn,
+	"return":   Return,
+	"for":      For,
+	"in":       In,
+	"while":    While,
+	"break":    Break,
+	"continue": Continue,
+	"list":     List,
+	"nil":      Nil,
 }
 
 type Token struct {
@@ -63,6 +76,44 @@ type Token struct {
 	Literal string
 	Line    int
 	Col     int
+
+	// Offset is the byte offset of the token in the input. EndOffset, EndLine
+	// and EndCol are the position just past its last character.
+	Offset    int
+	EndOffset int
+	EndLine   int
+	EndCol    int
+
+	// Comments are the comments between the previous token that is not a
+	// Delimiter and this one.
+	Comments []Comment
+}
+
+// Comment is a line or block comment. Comments are not tokens of their own,
+// but trivia carried by the token after them. Text includes the comment's
+// delimiters.
+type Comment struct {
+	Text string
+	Line int
+	Col  int
+}
+
+// Position is a place in the input: a byte offset, and the line and column
+// it is on.
+type Position struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+// Pos returns the position of the token's first character.
+func (t Token) Pos() Position {
+	return Position{Offset: t.Offset, Line: t.Line, Col: t.Col}
+}
+
+// End returns the position just past the token's last character.
+func (t Token) End() Position {
+	return Position{Offset: t.EndOffset, Line: t.EndLine, Col: t.EndCol}
 }
 
 func NewFromByte(tokType Type, char byte, line, col int) Token {
@@ -0,0 +1,138 @@
+package types
+
+import (
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/diagnostics"
+)
+
+// builtin checks a call to a builtin, given the types of its arguments, and
+// returns the type of its result. Many builtins take any number of
+// arguments, or values of more than one type, so they are checked by hand
+// rather than given a function type. The number of arguments is left for
+// the builtin to check when it is called.
+type builtin func(c *checker, call ast.FnCall, args []Type) Type
+
+var builtins = map[string]builtin{
+	"print": func(c *checker, call ast.FnCall, args []Type) Type { return Nil },
+	"int":   func(c *checker, call ast.FnCall, args []Type) Type { return Int },
+	"float": func(c *checker, call ast.FnCall, args []Type) Type { return Float },
+	"len":   func(c *checker, call ast.FnCall, args []Type) Type { return Int },
+	"get":   checkGet,
+	"set": func(c *checker, call ast.FnCall, args []Type) Type {
+		list := c.listArgument(call, args)
+		c.argument(call, args, 1, Int)
+		c.argument(call, args, 2, list.Elem)
+		return list
+	},
+	"push": func(c *checker, call ast.FnCall, args []Type) Type {
+		list := c.listArgument(call, args)
+		for i := 1; i < len(args); i++ {
+			c.argument(call, args, i, list.Elem)
+		}
+		return list
+	},
+	"pop": func(c *checker, call ast.FnCall, args []Type) Type {
+		return c.listArgument(call, args).Elem
+	},
+	"slice": func(c *checker, call ast.FnCall, args []Type) Type {
+		list := c.listArgument(call, args)
+		c.argument(call, args, 1, Int)
+		c.argument(call, args, 2, Int)
+		return list
+	},
+	"concat": func(c *checker, call ast.FnCall, args []Type) Type {
+		list := &List{Elem: c.fresh()}
+		for i := range args {
+			c.argument(call, args, i, list)
+		}
+		return list
+	},
+	"map": func(c *checker, call ast.FnCall, args []Type) Type {
+		m := &Map{Key: c.fresh(), Value: c.fresh()}
+		for i := range args {
+			if i%2 == 0 {
+				c.argument(call, args, i, m.Key)
+			} else {
+				c.argument(call, args, i, m.Value)
+			}
+		}
+		return m
+	},
+	"put": func(c *checker, call ast.FnCall, args []Type) Type {
+		m := c.mapArgument(call, args)
+		c.argument(call, args, 1, m.Key)
+		c.argument(call, args, 2, m.Value)
+		return m
+	},
+	"delete": func(c *checker, call ast.FnCall, args []Type) Type {
+		m := c.mapArgument(call, args)
+		c.argument(call, args, 1, m.Key)
+		return m
+	},
+	"has": func(c *checker, call ast.FnCall, args []Type) Type {
+		m := c.mapArgument(call, args)
+		c.argument(call, args, 1, m.Key)
+		return Bool
+	},
+	"keys": func(c *checker, call ast.FnCall, args []Type) Type {
+		return &List{Elem: c.mapArgument(call, args).Key}
+	},
+	"values": func(c *checker, call ast.FnCall, args []Type) Type {
+		return &List{Elem: c.mapArgument(call, args).Value}
+	},
+	"range": func(c *checker, call ast.FnCall, args []Type) Type {
+		for i := range args {
+			c.argument(call, args, i, Int)
+		}
+		return &List{Elem: Int}
+	},
+}
+
+// checkGet checks a call to get, which takes an element of a list by its
+// index or the value of a key of a map. If it is not known yet which of the
+// two it is given, neither its arguments nor its result can be checked.
+func checkGet(c *checker, call ast.FnCall, args []Type) Type {
+	if len(args) == 0 {
+		return c.fresh()
+	}
+
+	switch collection := prune(args[0]).(type) {
+	case *Map:
+		c.argument(call, args, 1, collection.Key)
+		return collection.Value
+	case *Var:
+		return c.fresh()
+	default:
+		list := c.listArgument(call, args)
+		c.argument(call, args, 1, Int)
+		return list.Elem
+	}
+}
+
+// argument checks that the i-th argument of a call to a builtin, if it was
+// given one, is of type want.
+func (c *checker) argument(call ast.FnCall, args []Type, i int, want Type) {
+	if i >= len(args) {
+		return
+	}
+	if want, got, ok := c.unify(want, args[i]); !ok {
+		c.errorf(diagnostics.TypeMismatch, nodeSpan(call.Arguments[i]),
+			"expected argument %d of '%s' to be '%s', but got '%s'", i+1, call.TokenLiteral(), want, got)
+	}
+}
+
+// listArgument checks that the first argument of a call is a list, and
+// returns its type.
+func (c *checker) listArgument(call ast.FnCall, args []Type) *List {
+	list := &List{Elem: c.fresh()}
+	c.argument(call, args, 0, list)
+	return list
+}
+
+// mapArgument checks that the first argument of a call is a map, and returns
+// its type.
+func (c *checker) mapArgument(call ast.FnCall, args []Type) *Map {
+	m := &Map{Key: c.fresh(), Value: c.fresh()}
+	c.argument(call, args, 0, m)
+	return m
+}
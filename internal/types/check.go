@@ -0,0 +1,706 @@
+// Package types infers the types of the names a program declares, and
+// reports operations on values of the wrong type before the program runs.
+//
+// Types are inferred in the style of Hindley and Milner: each name starts
+// out as a type variable, which is bound to a type by the way the name is
+// used. Functions declared with fn are generalized, so they can be called
+// with arguments of different types.
+package types
+
+import (
+	"sort"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/diagnostics"
+	"github.com/avearmin/simple/internal/resolver"
+	"github.com/avearmin/simple/internal/token"
+)
+
+// Info is what was inferred about a program.
+type Info struct {
+	// Types holds the type of each name the program declares, by the
+	// position of the Atom that declares it.
+	Types map[token.Position]Type
+}
+
+// Check resolves the names used in program and infers their types.
+//
+// If the names cannot be resolved, the error is the diagnostics.List of the
+// resolver. Otherwise, if any operation is applied to values of the wrong
+// type, the error is a diagnostics.List of every one found, in the order
+// they appear in the source.
+func Check(program *ast.Program) (*Info, error) {
+	bindings, err := resolver.Resolve(program)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &checker{bindings: bindings, names: map[token.Position]*scheme{}}
+	c.statements(program.Statements)
+	c.checkOperands()
+	c.defaultNumbers()
+
+	info := &Info{Types: make(map[token.Position]Type, len(c.names))}
+	for pos, s := range c.names {
+		info.Types[pos] = resolve(s.t)
+	}
+
+	if len(c.diags) > 0 {
+		sort.SliceStable(c.diags, func(i, j int) bool {
+			a, b := c.diags[i].Span.Start, c.diags[j].Span.Start
+			return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
+		})
+		return info, c.diags
+	}
+	return info, nil
+}
+
+// scheme is the type of a name. The variables in vars stand for a new type
+// each time the name is used.
+type scheme struct {
+	vars []*Var
+	t    Type
+}
+
+// function is a function whose body is being checked.
+type function struct {
+	name   string
+	result Type
+
+	// first is the first return statement of the function.
+	first *ast.ReturnStatement
+}
+
+// describe names the function in messages.
+func (fn *function) describe() string {
+	if fn.name == "" {
+		return "the function"
+	}
+	return "'" + fn.name + "'"
+}
+
+// operand is an arithmetic or comparison operation whose operands were both
+// of a type not known yet when it was checked.
+type operand struct {
+	exp ast.BinaryExpression
+	t   Type
+}
+
+type checker struct {
+	bindings resolver.Bindings
+	names    map[token.Position]*scheme
+
+	level     int
+	nextVar   int
+	functions []*function
+	operands  []operand
+	diags     diagnostics.List
+}
+
+func (c *checker) fresh() *Var {
+	c.nextVar++
+	return &Var{id: c.nextVar, level: c.level}
+}
+
+// number returns a new numeric variable.
+func (c *checker) number() *Var {
+	v := c.fresh()
+	v.numeric = true
+	return v
+}
+
+// unify unifies want and got. If they cannot be unified, it returns them as
+// they were written before any of their variables were bound.
+func (c *checker) unify(want, got Type) (string, string, bool) {
+	wantString, gotString := want.String(), got.String()
+	if !unify(want, got) {
+		return wantString, gotString, false
+	}
+	return "", "", true
+}
+
+func (c *checker) errorf(code diagnostics.Code, span diagnostics.Span, format string, a ...any) *diagnostics.Diagnostic {
+	d := diagnostics.New(code, span, format, a...)
+	c.diags = append(c.diags, d)
+	return d
+}
+
+// lookup returns the type of the name atom refers to. Builtins may be used
+// in many ways, so the type of one that is not called is not known.
+func (c *checker) lookup(atom ast.Atom) Type {
+	decl, ok := c.bindings.Of(atom)
+	if !ok || decl.Kind == resolver.Builtin {
+		return c.fresh()
+	}
+
+	pos := decl.Name.Token.Pos()
+	if s, ok := c.names[pos]; ok {
+		return c.instantiate(s)
+	}
+
+	// The name is declared after the function body it is used in. Its
+	// variable is made at the outermost level, so it is never generalized.
+	v := c.fresh()
+	v.level = 0
+	c.names[pos] = &scheme{t: v}
+	return v
+}
+
+// declare gives the name declared by name the type t. A name declared as an
+// int may be reassigned a float, so it is given a numeric variable instead.
+func (c *checker) declare(name ast.Atom, t Type) {
+	if prune(t) == Int {
+		t = c.number()
+	}
+
+	pos := name.Token.Pos()
+	s, ok := c.names[pos]
+	if !ok {
+		c.names[pos] = &scheme{t: t}
+		return
+	}
+
+	if want, got, ok := c.unify(s.t, t); !ok {
+		c.errorf(diagnostics.TypeMismatch, diagnostics.TokenSpan(name.Token),
+			"cannot assign '%s' to '%s' of type '%s'", got, name.Value, want).
+			Note("'%s' is used as '%s' before it is declared", name.Value, want)
+	}
+}
+
+// generalize makes a scheme of t in which the variables that are not used
+// outside the current function declaration stand for any type.
+func (c *checker) generalize(t Type) *scheme {
+	s := &scheme{t: t}
+	seen := map[*Var]bool{}
+
+	var collect func(t Type)
+	collect = func(t Type) {
+		switch t := prune(t).(type) {
+		case *Var:
+			if t.level > c.level && !seen[t] {
+				seen[t] = true
+				s.vars = append(s.vars, t)
+			}
+		case *List:
+			collect(t.Elem)
+		case *Map:
+			collect(t.Key)
+			collect(t.Value)
+		case *Func:
+			for _, param := range t.Params {
+				collect(param)
+			}
+			collect(t.Result)
+		}
+	}
+
+	collect(t)
+	return s
+}
+
+// instantiate returns the type of s, with a new variable for each of the
+// variables of s.
+func (c *checker) instantiate(s *scheme) Type {
+	if len(s.vars) == 0 {
+		return s.t
+	}
+
+	fresh := make(map[*Var]Type, len(s.vars))
+	for _, v := range s.vars {
+		w := c.fresh()
+		w.numeric = v.numeric
+		fresh[v] = w
+	}
+
+	var copy func(t Type) Type
+	copy = func(t Type) Type {
+		switch t := prune(t).(type) {
+		case *Var:
+			if v, ok := fresh[t]; ok {
+				return v
+			}
+			return t
+		case *List:
+			return &List{Elem: copy(t.Elem)}
+		case *Map:
+			return &Map{Key: copy(t.Key), Value: copy(t.Value)}
+		case *Func:
+			params := make([]Type, len(t.Params))
+			for i, param := range t.Params {
+				params[i] = copy(param)
+			}
+			return &Func{Params: params, Result: copy(t.Result)}
+		default:
+			return t
+		}
+	}
+
+	return copy(s.t)
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		c.statement(stmt)
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case ast.AssignStatement:
+		c.declare(stmt.Name, c.expression(stmt.Value))
+	case ast.ReassignStatement:
+		t := c.expression(stmt.Value)
+		if want, got, ok := c.unify(c.lookup(stmt.Name), t); !ok {
+			c.errorf(diagnostics.TypeMismatch, nodeSpan(stmt.Value),
+				"cannot assign '%s' to '%s' of type '%s'", got, stmt.Name.Value, want)
+		}
+	case ast.ConditionalStatement:
+		c.condition(stmt.IfCondition)
+		c.statements(stmt.IfStatements)
+		for _, elif := range stmt.ElifBlocks {
+			c.condition(elif.Condition)
+			c.statements(elif.Statements)
+		}
+		c.statements(stmt.ElseBlock.Statements)
+	case ast.FunctionAssignStatement:
+		c.functionAssign(stmt)
+	case ast.ReturnStatement:
+		c.returnStatement(stmt)
+	case ast.ForLoopStatement:
+		if stmt.Initalizer != nil {
+			c.statement(stmt.Initalizer)
+		}
+		if stmt.Condition != nil {
+			c.condition(stmt.Condition)
+		}
+		c.statements(stmt.Statements)
+		if stmt.Update != nil {
+			c.statement(stmt.Update)
+		}
+	case ast.ForEachStatement:
+		c.forEach(stmt)
+	case ast.WhileStatement:
+		c.condition(stmt.Condition)
+		c.statements(stmt.Statements)
+	case ast.FnCall:
+		c.call(stmt)
+	}
+}
+
+// condition checks that exp is a bool.
+func (c *checker) condition(exp ast.Expression) {
+	if _, got, ok := c.unify(Bool, c.expression(exp)); !ok {
+		c.errorf(diagnostics.NonBoolCondition, nodeSpan(exp), "expected condition to be 'bool', but got '%s'", got)
+	}
+}
+
+// functionAssign checks a function declaration, and generalizes its type.
+// A function used before it is declared keeps the type it was used with.
+func (c *checker) functionAssign(stmt ast.FunctionAssignStatement) {
+	pos := stmt.Name.Token.Pos()
+	if _, ok := c.names[pos]; ok {
+		c.declare(stmt.Name, c.function(stmt.Name.Value, stmt.Params, stmt.Statements, stmt.RParen))
+		return
+	}
+
+	c.level++
+	self := c.fresh()
+	c.names[pos] = &scheme{t: self}
+	t := c.function(stmt.Name.Value, stmt.Params, stmt.Statements, stmt.RParen)
+	unify(self, t)
+	c.level--
+
+	c.names[pos] = c.generalize(t)
+}
+
+// function checks the body of a function, and returns its type. end is the
+// closing parenthesis of the function, which is where a body that can reach
+// its end returns nil.
+func (c *checker) function(name string, params []ast.Atom, stmts []ast.Statement, end token.Token) Type {
+	paramTypes := make([]Type, len(params))
+	for i, param := range params {
+		paramTypes[i] = c.fresh()
+		c.declare(param, paramTypes[i])
+	}
+
+	fn := &function{name: name, result: c.fresh()}
+	c.functions = append(c.functions, fn)
+	c.statements(stmts)
+	c.functions = c.functions[:len(c.functions)-1]
+
+	if !alwaysReturns(stmts) {
+		if want, _, ok := c.unify(fn.result, Nil); !ok {
+			d := c.errorf(diagnostics.InconsistentReturn, diagnostics.TokenSpan(end),
+				"expected %s to return '%s', but it can reach its end, where it returns 'nil'", fn.describe(), want)
+			c.noteFirstReturn(d, fn)
+		}
+	}
+
+	return &Func{Params: paramTypes, Result: fn.result}
+}
+
+func (c *checker) returnStatement(stmt ast.ReturnStatement) {
+	var t Type = Nil
+	if stmt.Value != nil {
+		t = c.expression(stmt.Value)
+	}
+
+	if len(c.functions) == 0 {
+		return
+	}
+	fn := c.functions[len(c.functions)-1]
+
+	if want, got, ok := c.unify(fn.result, t); !ok {
+		s := diagnostics.TokenSpan(stmt.Token)
+		if stmt.Value != nil {
+			s = nodeSpan(stmt.Value)
+		}
+		d := c.errorf(diagnostics.InconsistentReturn, s, "expected %s to return '%s', but got '%s'", fn.describe(), want, got)
+		c.noteFirstReturn(d, fn)
+		return
+	}
+	if fn.first == nil {
+		fn.first = &stmt
+	}
+}
+
+func (c *checker) noteFirstReturn(d *diagnostics.Diagnostic, fn *function) {
+	if fn.first != nil {
+		d.Note("%s first returns on line %d col %d", fn.describe(), fn.first.Token.Line, fn.first.Token.Col)
+	}
+}
+
+// alwaysReturns reports whether running stmts always ends in a return
+// statement.
+func alwaysReturns(stmts []ast.Statement) bool {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case ast.ReturnStatement:
+			return true
+		case ast.ConditionalStatement:
+			if everyBranchReturns(stmt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// everyBranchReturns reports whether every branch of stmt, including its
+// else block, always returns.
+func everyBranchReturns(stmt ast.ConditionalStatement) bool {
+	if !alwaysReturns(stmt.IfStatements) || !alwaysReturns(stmt.ElseBlock.Statements) {
+		return false
+	}
+	for _, elif := range stmt.ElifBlocks {
+		if !alwaysReturns(elif.Statements) {
+			return false
+		}
+	}
+	return true
+}
+
+// forEach gives the names of a loop the types of the items of a list or map.
+// The items of a list are its indexes and elements, and those of a map its
+// keys and values.
+func (c *checker) forEach(stmt ast.ForEachStatement) {
+	iterable := c.expression(stmt.Iterable)
+
+	var index, item Type
+	switch t := prune(iterable).(type) {
+	case *List:
+		index, item = Int, t.Elem
+	case *Map:
+		index, item = t.Key, t.Value
+	case *Var:
+		index, item = c.fresh(), c.fresh()
+	default:
+		c.errorf(diagnostics.TypeMismatch, nodeSpan(stmt.Iterable), "cannot iterate over a value of type '%s'", iterable)
+		index, item = c.fresh(), c.fresh()
+	}
+
+	if len(stmt.Names) == 1 {
+		if _, ok := prune(iterable).(*Map); ok {
+			item = index
+		}
+		c.declare(stmt.Names[0], item)
+	} else if len(stmt.Names) == 2 {
+		c.declare(stmt.Names[0], index)
+		c.declare(stmt.Names[1], item)
+	}
+
+	c.statements(stmt.Statements)
+}
+
+func (c *checker) expression(exp ast.Expression) Type {
+	switch exp := exp.(type) {
+	case ast.Atom:
+		switch exp.Token.Type {
+		case token.Int:
+			return Int
+		case token.Float:
+			return Float
+		case token.Bool:
+			return Bool
+		case token.String:
+			return String
+		case token.Nil:
+			return Nil
+		case token.Ident:
+			return c.lookup(exp)
+		}
+	case ast.BinaryExpression:
+		return c.binary(exp)
+	case ast.FunctionLiteral:
+		return c.function("", exp.Params, exp.Statements, exp.RParen)
+	case ast.ListLiteral:
+		elem := c.fresh()
+		for _, element := range exp.Elements {
+			if want, got, ok := c.unify(elem, c.expression(element)); !ok {
+				c.errorf(diagnostics.TypeMismatch, nodeSpan(element), "expected list elements to be '%s', but got '%s'", want, got)
+			}
+		}
+		return &List{Elem: elem}
+	case ast.LogicalExpression:
+		for _, operand := range exp.Operands {
+			if _, got, ok := c.unify(Bool, c.expression(operand)); !ok {
+				c.errorf(diagnostics.TypeMismatch, nodeSpan(operand),
+					"expected operands of '%s' to be 'bool', but got '%s'", exp.TokenLiteral(), got)
+			}
+		}
+		return Bool
+	case ast.UnaryExpression:
+		if _, got, ok := c.unify(Bool, c.expression(exp.Operand)); !ok {
+			c.errorf(diagnostics.TypeMismatch, nodeSpan(exp.Operand),
+				"expected operand of '%s' to be 'bool', but got '%s'", exp.TokenLiteral(), got)
+		}
+		return Bool
+	case ast.FnCall:
+		return c.call(exp)
+	}
+	return c.fresh()
+}
+
+// binary checks the operands of exp. Numbers of different types may be
+// mixed, as an int is promoted to a float. Where the type of one operand is
+// not known yet, it is taken to be the type of the other, or to be a number
+// if the other is one.
+func (c *checker) binary(exp ast.BinaryExpression) Type {
+	first, second := c.expression(exp.First), c.expression(exp.Second)
+	op := exp.TokenType()
+
+	if op == token.Equals || op == token.NotEquals {
+		if prune(first) == Nil || prune(second) == Nil {
+			return Bool
+		}
+		if isVar(first) && !isVar(second) {
+			c.inferOperand(first, second)
+		} else if isVar(second) && !isVar(first) {
+			c.inferOperand(second, first)
+		}
+		if isNumber(first) && isNumber(second) {
+			return Bool
+		}
+		if _, _, ok := c.unify(first, second); !ok {
+			c.operandError(exp, first, second)
+		}
+		return Bool
+	}
+
+	var result Type
+	switch {
+	case isVar(first) && isVar(second):
+		unify(first, second)
+		c.operands = append(c.operands, operand{exp: exp, t: first})
+		result = first
+	case isVar(first):
+		c.inferOperand(first, second)
+		result = c.operatorResult(exp, first, second)
+	case isVar(second):
+		c.inferOperand(second, first)
+		result = c.operatorResult(exp, first, second)
+	default:
+		result = c.operatorResult(exp, first, second)
+	}
+
+	if isComparison(op) {
+		return Bool
+	}
+	return result
+}
+
+// inferOperand infers the type of the operand v from the type of the other
+// operand. The operand used with a number is a number too, but which one is
+// left to the rest of the program.
+func (c *checker) inferOperand(v, other Type) {
+	if isNumber(other) {
+		prune(v).(*Var).numeric = true
+		return
+	}
+	unify(v, other)
+}
+
+func (c *checker) operatorResult(exp ast.BinaryExpression, first, second Type) Type {
+	if t, ok := operatorResult(exp.TokenType(), first, second); ok {
+		return t
+	}
+	c.operandError(exp, first, second)
+	return c.fresh()
+}
+
+// operatorResult returns the type of applying op to operands of the given
+// types, and reports false if op cannot be applied to them.
+func operatorResult(op token.Type, first, second Type) (Type, bool) {
+	first, second = prune(first), prune(second)
+
+	switch {
+	case isNumber(first) && isNumber(second):
+		if first == Float || second == Float {
+			return Float, true
+		}
+		if first == Int {
+			return second, true
+		}
+		return first, true
+	case op == token.Add && first == String && second == String:
+		return String, true
+	default:
+		return nil, false
+	}
+}
+
+func isComparison(op token.Type) bool {
+	switch op {
+	case token.LessThan, token.GreaterThan, token.LessThanOrEquals, token.GreaterThanOrEquals:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkOperands checks the operations whose operands were of a type not
+// known when they were checked, now that every type that can be has been
+// inferred.
+func (c *checker) checkOperands() {
+	for _, operand := range c.operands {
+		if isVar(operand.t) {
+			continue
+		}
+		if _, ok := operatorResult(operand.exp.TokenType(), operand.t, operand.t); !ok {
+			c.operandError(operand.exp, operand.t, operand.t)
+		}
+	}
+}
+
+// defaultNumbers makes int the type of the numeric variables left in the
+// types of names, as no float was ever given to them. The variables of
+// generalized functions stand for any number, so they are left as they are.
+func (c *checker) defaultNumbers() {
+	generalized := map[*Var]bool{}
+	for _, s := range c.names {
+		for _, v := range s.vars {
+			generalized[v] = true
+		}
+	}
+
+	var walk func(t Type)
+	walk = func(t Type) {
+		switch t := prune(t).(type) {
+		case *Var:
+			if t.numeric && !generalized[t] {
+				t.bound = Int
+			}
+		case *List:
+			walk(t.Elem)
+		case *Map:
+			walk(t.Key)
+			walk(t.Value)
+		case *Func:
+			for _, param := range t.Params {
+				walk(param)
+			}
+			walk(t.Result)
+		}
+	}
+
+	for _, s := range c.names {
+		walk(s.t)
+	}
+}
+
+func (c *checker) operandError(exp ast.BinaryExpression, first, second Type) {
+	s := diagnostics.TokenSpan(exp.Token)
+	if first.String() == second.String() {
+		c.errorf(diagnostics.OperandMismatch, s, "operator '%s' is not defined for '%s'", exp.TokenLiteral(), first)
+		return
+	}
+	c.errorf(diagnostics.OperandMismatch, s, "mismatched types '%s' and '%s' for '%s'", first, second, exp.TokenLiteral())
+}
+
+// call checks the arguments of a call against the parameters of the
+// function called, and returns the type of its result.
+func (c *checker) call(call ast.FnCall) Type {
+	args := make([]Type, len(call.Arguments))
+	if callee, ok := call.Callee.(ast.Atom); ok {
+		if decl, ok := c.bindings.Of(callee); ok && decl.Kind == resolver.Builtin {
+			for i, arg := range call.Arguments {
+				args[i] = c.expression(arg)
+			}
+			if check, ok := builtins[decl.Name.Value]; ok {
+				return check(c, call, args)
+			}
+			return c.fresh()
+		}
+	}
+
+	callee := c.expression(call.Callee)
+	for i, arg := range call.Arguments {
+		args[i] = c.expression(arg)
+	}
+
+	name := "the function"
+	if atom, ok := call.Callee.(ast.Atom); ok {
+		name = "'" + atom.Value + "'"
+	}
+
+	switch fn := prune(callee).(type) {
+	case *Func:
+		if len(fn.Params) != len(args) {
+			c.errorf(diagnostics.TypeMismatch, nodeSpan(call),
+				"function %s expects %d arguments, but got %d", name, len(fn.Params), len(args))
+			return fn.Result
+		}
+		for i := range args {
+			if want, got, ok := c.unify(fn.Params[i], args[i]); !ok {
+				c.errorf(diagnostics.TypeMismatch, nodeSpan(call.Arguments[i]),
+					"expected argument %d of %s to be '%s', but got '%s'", i+1, name, want, got)
+			}
+		}
+		return fn.Result
+	case *Var:
+		result := c.fresh()
+		t := &Func{Params: args, Result: result}
+		// A function that is given itself, or a value made from itself,
+		// would have a type that contains its own type.
+		if occurs(fn, t) {
+			formatted := formatTypes(t, fn)
+			c.errorf(diagnostics.InfiniteType, nodeSpan(call.Callee),
+				"infinite type: %s would have to be '%s', which contains its own type '%s'", name, formatted[0], formatted[1])
+			return result
+		}
+		unify(fn, t)
+		return result
+	default:
+		c.errorf(diagnostics.TypeMismatch, nodeSpan(call.Callee), "cannot call a value of type '%s'", callee)
+		return c.fresh()
+	}
+}
+
+// nodeSpan returns the span of node.
+func nodeSpan(node ast.Node) diagnostics.Span {
+	s := node.Span()
+	return diagnostics.Span{
+		Start: diagnostics.Position{Line: s.Start.Line, Col: s.Start.Col},
+		End:   diagnostics.Position{Line: s.End.Line, Col: s.End.Col},
+	}
+}
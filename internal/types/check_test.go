@@ -0,0 +1,239 @@
+package types
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/avearmin/simple/internal/ast"
+	"github.com/avearmin/simple/internal/diagnostics"
+	"github.com/avearmin/simple/internal/lexer"
+	"github.com/avearmin/simple/internal/parser"
+)
+
+func TestCheck(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  []string
+	}{
+		"literals": {
+			input: "(:= a 1)\n(:= b 2.5)\n(:= c true)\n(:= d \"s\")\n(:= e nil)",
+			want:  []string{"a: int", "b: float", "c: bool", "d: string", "e: nil"},
+		},
+		"operators": {
+			input: "(:= x (+ 1 2.5))\n(:= y (* 2 3))\n(:= z (< 1 2))\n(:= s (+ \"a\" \"b\"))\n(:= e (== 1 nil))",
+			want:  []string{"e: bool", "s: string", "x: float", "y: int", "z: bool"},
+		},
+		"parameters are inferred from their use": {
+			input: "(fn double x (return (* x 2)))",
+			want:  []string{"double: fn(number) number", "x: number"},
+		},
+		"float arguments to numeric parameters": {
+			input: "(fn half x (return (/ x 2)))\n(:= a (half 3.0))\n(:= b (half 4))",
+			want:  []string{"a: float", "b: int", "half: fn(number) number", "x: number"},
+		},
+		"numbers default to int": {
+			input: "(:= n 1)\n(:= m (+ n 2))\n(= n (* n 3))",
+			want:  []string{"m: int", "n: int"},
+		},
+		"reassigning a float to an int": {
+			input: "(:= y 1)\n(= y 2.5)\n(:= z (+ y 1))",
+			want:  []string{"y: float", "z: float"},
+		},
+		"functions are generalized": {
+			input: "(fn id x (return x))\n(:= a (id 1))\n(:= b (id true))",
+			want:  []string{"a: int", "b: bool", "id: fn(a) a", "x: a"},
+		},
+		"recursion": {
+			input: "(fn fact n (if (< n 2) (return 1)) (return (* n (fact (- n 1)))))",
+			want:  []string{"fact: fn(int) int", "n: int"},
+		},
+		"every branch returns": {
+			input: "(fn sign n (if (< n 0) (return (- 0 1)) elif (> n 0) (return 1) else (return 0)))",
+			want:  []string{"n: number", "sign: fn(number) int"},
+		},
+		"no return is nil": {
+			input: "(fn greet name (print name))",
+			want:  []string{"greet: fn(a) nil", "name: a"},
+		},
+		"lists and maps": {
+			input: "(:= xs (push (list 1) 2))\n(:= m (map \"a\" 1))\n(for k v in m (:= s (+ k \"!\")))\n(:= n (get xs 0))\n(:= ks (keys m))",
+			want: []string{
+				"k: string", "ks: list[string]", "m: map[string]int", "n: int",
+				"s: string", "v: int", "xs: list[int]",
+			},
+		},
+		"closures": {
+			input: "(:= count 0)\n(:= inc (fn () (= count (+ count 1)) (return count)))",
+			want:  []string{"count: int", "inc: fn() int"},
+		},
+		"function body uses a later name": {
+			input: "(fn f (return (+ y 1)))\n(:= y 2)",
+			want:  []string{"f: fn() int", "y: int"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			program := parse(t, test.input)
+			info, err := Check(program)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := describe(program, info)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got=%q, want=%q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  diagnostics.List
+	}{
+		"mismatched operands": {
+			input: "(:= x (+ true 1))",
+			want: diagnostics.List{{
+				Code: diagnostics.OperandMismatch, Span: span(1, 7, 8), Message: "mismatched types 'bool' and 'int' for '+'",
+			}},
+		},
+		"operator not defined for the operands": {
+			input: "(:= s (- \"a\" \"b\"))",
+			want: diagnostics.List{{
+				Code: diagnostics.OperandMismatch, Span: span(1, 7, 8), Message: "operator '-' is not defined for 'string'",
+			}},
+		},
+		"operands inferred later": {
+			input: "(:= add (fn (x y) (return (+ x y))))\n(add true false)",
+			want: diagnostics.List{{
+				Code: diagnostics.OperandMismatch, Span: span(1, 27, 28), Message: "operator '+' is not defined for 'bool'",
+			}},
+		},
+		"if condition": {
+			input: "(if 1 (print 1))",
+			want: diagnostics.List{{
+				Code: diagnostics.NonBoolCondition, Span: span(1, 4, 5), Message: "expected condition to be 'bool', but got 'int'",
+			}},
+		},
+		"elif condition": {
+			input: "(if false (print 1) elif (list) (print 2))",
+			want: diagnostics.List{{
+				Code: diagnostics.NonBoolCondition, Span: span(1, 25, 31), Message: "expected condition to be 'bool', but got 'list[a]'",
+			}},
+		},
+		"inconsistent returns": {
+			input: "(fn f x (if x (return 1)) (return \"a\"))",
+			want: diagnostics.List{{
+				Code: diagnostics.InconsistentReturn, Span: span(1, 34, 37),
+				Message: "expected 'f' to return 'int', but got 'string'", Notes: []string{"'f' first returns on line 1 col 15"},
+			}},
+		},
+		"reaching the end returns nil": {
+			input: "(fn f x (if x (return 1)))",
+			want: diagnostics.List{{
+				Code: diagnostics.InconsistentReturn, Span: span(1, 25, 26),
+				Message: "expected 'f' to return 'int', but it can reach its end, where it returns 'nil'",
+				Notes:   []string{"'f' first returns on line 1 col 15"},
+			}},
+		},
+		"argument": {
+			input: "(fn f x (return (+ x 1)))\n(f true)",
+			want: diagnostics.List{{
+				Code: diagnostics.TypeMismatch, Span: span(2, 3, 7), Message: "expected argument 1 of 'f' to be 'number', but got 'bool'",
+			}},
+		},
+		"numeric argument": {
+			input: "(fn half x (return (/ x 2)))\n(half \"a\")",
+			want: diagnostics.List{{
+				Code: diagnostics.TypeMismatch, Span: span(2, 6, 9), Message: "expected argument 1 of 'half' to be 'number', but got 'string'",
+			}},
+		},
+		"argument of a builtin": {
+			input: "(push (list 1) \"a\")",
+			want: diagnostics.List{{
+				Code: diagnostics.TypeMismatch, Span: span(1, 15, 18), Message: "expected argument 2 of 'push' to be 'int', but got 'string'",
+			}},
+		},
+		"argument count": {
+			input: "(fn f x (return x))\n(f 1 2)",
+			want: diagnostics.List{{
+				Code: diagnostics.TypeMismatch, Span: span(2, 0, 7), Message: "function 'f' expects 1 arguments, but got 2",
+			}},
+		},
+		"reassignment": {
+			input: "(:= x 1)\n(= x \"a\")",
+			want: diagnostics.List{{
+				Code: diagnostics.TypeMismatch, Span: span(2, 5, 8), Message: "cannot assign 'string' to 'x' of type 'number'",
+			}},
+		},
+		"infinite type": {
+			input: "(fn self f (return (f f)))",
+			want: diagnostics.List{{
+				Code: diagnostics.InfiniteType, Span: span(1, 20, 21),
+				Message: "infinite type: 'f' would have to be 'fn(a) b', which contains its own type 'a'",
+			}},
+		},
+		"names are resolved first": {
+			input: "(print y)",
+			want: diagnostics.List{{
+				Code: diagnostics.UndefinedName, Span: span(1, 7, 8), Message: "undefined identifier 'y'",
+			}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Check(parse(t, test.input))
+			if !reflect.DeepEqual(err, test.want) {
+				t.Fatalf("got=%v, want=%v", err, test.want)
+			}
+		})
+	}
+}
+
+// describe lists the types of the names declared in program as
+// "name: type", in sorted order.
+func describe(program *ast.Program, info *Info) []string {
+	var described []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		var names []ast.Atom
+		switch node := node.(type) {
+		case ast.AssignStatement:
+			names = []ast.Atom{node.Name}
+		case ast.FunctionAssignStatement:
+			names = append([]ast.Atom{node.Name}, node.Params...)
+		case ast.FunctionLiteral:
+			names = node.Params
+		case ast.ForEachStatement:
+			names = node.Names
+		}
+		for _, name := range names {
+			described = append(described, name.Value+": "+info.Types[name.Token.Pos()].String())
+		}
+		return true
+	})
+
+	sort.Strings(described)
+	return described
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	program, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+// span returns the span of the columns from start up to end of a line.
+func span(line, start, end int) diagnostics.Span {
+	return diagnostics.Span{
+		Start: diagnostics.Position{Line: line, Col: start},
+		End:   diagnostics.Position{Line: line, Col: end},
+	}
+}
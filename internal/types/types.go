@@ -0,0 +1,275 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the type of a value. Types are built from basic types, lists,
+// maps and functions, and type variables that stand for a type that has not
+// been inferred yet.
+type Type interface {
+	String() string
+}
+
+type Basic string
+
+const (
+	Int    Basic = "int"
+	Float  Basic = "float"
+	Bool   Basic = "bool"
+	String Basic = "string"
+	Nil    Basic = "nil"
+)
+
+func (b Basic) String() string { return string(b) }
+
+type List struct {
+	Elem Type
+}
+
+func (l *List) String() string { return format(l) }
+
+type Map struct {
+	Key   Type
+	Value Type
+}
+
+func (m *Map) String() string { return format(m) }
+
+type Func struct {
+	Params []Type
+	Result Type
+}
+
+func (f *Func) String() string { return format(f) }
+
+// Var is a type variable. Once it is bound to a type it stands for that type
+// everywhere it appears.
+//
+// The level of an unbound variable is the number of function declarations
+// around the place it was made. A variable whose level is deeper than the
+// declaration being generalized is not used outside it, and so can stand for
+// any type.
+//
+// A numeric variable stands for int or float. It is made where a value of a
+// type not known yet is used with a number, as an int is promoted to a float
+// wherever the two are mixed.
+type Var struct {
+	id      int
+	level   int
+	numeric bool
+	bound   Type
+}
+
+func (v *Var) String() string { return format(v) }
+
+// prune follows the bindings of type variables to the type they stand for.
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.bound == nil {
+			return t
+		}
+		t = v.bound
+	}
+}
+
+// resolve returns t with every bound type variable replaced by its type.
+func resolve(t Type) Type {
+	switch t := prune(t).(type) {
+	case *List:
+		return &List{Elem: resolve(t.Elem)}
+	case *Map:
+		return &Map{Key: resolve(t.Key), Value: resolve(t.Value)}
+	case *Func:
+		params := make([]Type, len(t.Params))
+		for i, param := range t.Params {
+			params[i] = resolve(param)
+		}
+		return &Func{Params: params, Result: resolve(t.Result)}
+	default:
+		return t
+	}
+}
+
+// unify makes a and b the same type by binding type variables in them. It
+// reports false if they cannot be, in which case some variables may already
+// have been bound.
+func unify(a, b Type) bool {
+	a, b = prune(a), prune(b)
+
+	if v, ok := a.(*Var); ok {
+		return bind(v, b)
+	}
+	if v, ok := b.(*Var); ok {
+		return bind(v, a)
+	}
+
+	switch a := a.(type) {
+	case Basic:
+		return a == b
+	case *List:
+		b, ok := b.(*List)
+		return ok && unify(a.Elem, b.Elem)
+	case *Map:
+		b, ok := b.(*Map)
+		return ok && unify(a.Key, b.Key) && unify(a.Value, b.Value)
+	case *Func:
+		b, ok := b.(*Func)
+		if !ok || len(a.Params) != len(b.Params) {
+			return false
+		}
+		for i := range a.Params {
+			if !unify(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+		return unify(a.Result, b.Result)
+	default:
+		return false
+	}
+}
+
+// bind binds v to t, unless t contains v, in which case v would stand for a
+// type that contains itself, or v is numeric and t is not a number.
+func bind(v *Var, t Type) bool {
+	if t == Type(v) {
+		return true
+	}
+	if occurs(v, t) {
+		return false
+	}
+	if v.numeric {
+		if w, ok := t.(*Var); ok {
+			w.numeric = true
+		} else if t != Int && t != Float {
+			return false
+		}
+	}
+
+	adjustLevels(t, v.level)
+	v.bound = t
+	return true
+}
+
+func occurs(v *Var, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Var:
+		return t == v
+	case *List:
+		return occurs(v, t.Elem)
+	case *Map:
+		return occurs(v, t.Key) || occurs(v, t.Value)
+	case *Func:
+		for _, param := range t.Params {
+			if occurs(v, param) {
+				return true
+			}
+		}
+		return occurs(v, t.Result)
+	default:
+		return false
+	}
+}
+
+// adjustLevels lowers the level of the variables in t to level, as they are
+// now used wherever a variable of that level is.
+func adjustLevels(t Type, level int) {
+	switch t := prune(t).(type) {
+	case *Var:
+		t.level = min(t.level, level)
+	case *List:
+		adjustLevels(t.Elem, level)
+	case *Map:
+		adjustLevels(t.Key, level)
+		adjustLevels(t.Value, level)
+	case *Func:
+		for _, param := range t.Params {
+			adjustLevels(param, level)
+		}
+		adjustLevels(t.Result, level)
+	}
+}
+
+// isVar reports whether t is a type variable that is not bound yet.
+func isVar(t Type) bool {
+	_, ok := prune(t).(*Var)
+	return ok
+}
+
+// isNumber reports whether t is int, float or a numeric type variable.
+func isNumber(t Type) bool {
+	t = prune(t)
+	if v, ok := t.(*Var); ok {
+		return v.numeric
+	}
+	return t == Int || t == Float
+}
+
+// format writes t the way it is written in messages, naming its type
+// variables a, b, c and so on in the order they appear. A numeric variable
+// is written as number.
+func format(t Type) string {
+	return formatTypes(t)[0]
+}
+
+// formatTypes writes each of ts as format does, giving a type variable the
+// same name wherever it appears in them.
+func formatTypes(ts ...Type) []string {
+	var b strings.Builder
+	names := map[*Var]string{}
+
+	var write func(t Type)
+	write = func(t Type) {
+		switch t := prune(t).(type) {
+		case *Var:
+			if t.numeric {
+				b.WriteString("number")
+				return
+			}
+			name, ok := names[t]
+			if !ok {
+				name = varName(len(names))
+				names[t] = name
+			}
+			b.WriteString(name)
+		case *List:
+			b.WriteString("list[")
+			write(t.Elem)
+			b.WriteString("]")
+		case *Map:
+			b.WriteString("map[")
+			write(t.Key)
+			b.WriteString("]")
+			write(t.Value)
+		case *Func:
+			b.WriteString("fn(")
+			for i, param := range t.Params {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				write(param)
+			}
+			b.WriteString(") ")
+			write(t.Result)
+		default:
+			b.WriteString(t.String())
+		}
+	}
+
+	formatted := make([]string, len(ts))
+	for i, t := range ts {
+		write(t)
+		formatted[i] = b.String()
+		b.Reset()
+	}
+	return formatted
+}
+
+func varName(i int) string {
+	if i < 26 {
+		return string(rune('a' + i))
+	}
+	return fmt.Sprintf("t%d", i)
+}
@@ -0,0 +1,485 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/avearmin/simple/internal/code"
+	"github.com/avearmin/simple/internal/compiler"
+	"github.com/avearmin/simple/internal/object"
+	"github.com/avearmin/simple/internal/token"
+)
+
+const (
+	StackSize = 2048
+	MaxFrames = object.MaxCallDepth
+)
+
+var (
+	True  = object.Boolean{Value: true}
+	False = object.Boolean{Value: false}
+	Nil   = object.Nil{}
+)
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // always points to the next free slot; the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	out io.Writer
+}
+
+// New creates a VM that runs bytecode, writing the output of the program to
+// out.
+func New(bytecode *compiler.Bytecode, out io.Writer) *VM {
+	mainFrame := NewFrame(&object.Closure{Fn: bytecode.Main}, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, len(bytecode.GlobalNames)),
+		globalNames: bytecode.GlobalNames,
+		stack:       make([]object.Object, StackSize),
+		sp:          bytecode.Main.NumLocals,
+		frames:      frames,
+		framesIndex: 1,
+		out:         out,
+	}
+}
+
+// Run executes the program until its main function returns, and returns the
+// value it returned.
+func (vm *VM) Run() (object.Object, error) {
+	for {
+		frame := vm.currentFrame()
+		frame.ip++
+
+		ins := frame.Instructions()
+		ip := frame.ip
+		op := code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return nil, err
+			}
+
+		case code.OpNil:
+			if err := vm.push(Nil); err != nil {
+				return nil, err
+			}
+
+		case code.OpTrue:
+			if err := vm.push(True); err != nil {
+				return nil, err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(False); err != nil {
+				return nil, err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSubtract, code.OpMultiply, code.OpDivide, code.OpModulo,
+			code.OpEquals, code.OpNotEquals, code.OpLessThan, code.OpGreaterThan,
+			code.OpLessThanOrEquals, code.OpGreaterThanOrEquals:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return nil, err
+			}
+
+		case code.OpNot:
+			operand := vm.pop()
+			boolean, ok := operand.(object.Boolean)
+			if !ok {
+				return nil, vm.newError(object.TypeMismatchError,
+					"expected operand of '%s' to be '%s', but got '%s'", token.Not, object.BooleanObj, operand.Type())
+			}
+			if err := vm.push(nativeBoolToBoolean(!boolean.Value)); err != nil {
+				return nil, err
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip = pos - 1
+
+		case code.OpJumpFalseOrPop, code.OpJumpTrueOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			operator, decisive := token.And, false
+			if op == code.OpJumpTrueOrPop {
+				operator, decisive = token.Or, true
+			}
+
+			value := vm.stack[vm.sp-1]
+			boolean, ok := value.(object.Boolean)
+			if !ok {
+				return nil, vm.newError(object.TypeMismatchError,
+					"expected operands of '%s' to be '%s', but got '%s'", operator, object.BooleanObj, value.Type())
+			}
+			if boolean.Value == decisive {
+				frame.ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpJumpNotTrue:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			value := vm.pop()
+			condition, ok := value.(object.Boolean)
+			if !ok {
+				return nil, vm.newError(object.TypeMismatchError,
+					"expected condition to be '%s', but got '%s'", object.BooleanObj, value.Type())
+			}
+			if !condition.Value {
+				frame.ip = pos - 1
+			}
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			value := vm.globals[globalIndex]
+			if value == nil {
+				return nil, vm.newError(object.UndefinedIdentifierError,
+					"undefined identifier '%s'", vm.globalNames[globalIndex])
+			}
+			if err := vm.push(value); err != nil {
+				return nil, err
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpReassignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			if vm.globals[globalIndex] == nil {
+				return nil, vm.newError(object.ReassignBeforeAssignError,
+					"cannot reassign '%s' before it is assigned", vm.globalNames[globalIndex])
+			}
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpDefineLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			vm.stack[frame.basePointer+int(localIndex)] = &object.Cell{Value: vm.pop()}
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			cell := vm.stack[frame.basePointer+int(localIndex)].(*object.Cell)
+			if err := vm.push(cell.Value); err != nil {
+				return nil, err
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			cell := vm.stack[frame.basePointer+int(localIndex)].(*object.Cell)
+			cell.Value = vm.pop()
+
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			if err := vm.push(vm.stack[frame.basePointer+int(localIndex)]); err != nil {
+				return nil, err
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			if err := vm.push(frame.cl.Free[freeIndex].Value); err != nil {
+				return nil, err
+			}
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			frame.cl.Free[freeIndex].Value = vm.pop()
+
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			if err := vm.push(frame.cl.Free[freeIndex]); err != nil {
+				return nil, err
+			}
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			if err := vm.push(object.Builtins[builtinIndex].Builtin); err != nil {
+				return nil, err
+			}
+
+		case code.OpList:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(&object.List{Elements: elements}); err != nil {
+				return nil, err
+			}
+
+		case code.OpIter:
+			count := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 1
+
+			iterable := vm.pop()
+			iterator, ok := object.NewIterator(iterable, count)
+			if !ok {
+				return nil, vm.newError(object.TypeMismatchError, "cannot iterate over a value of type %s", iterable.Type())
+			}
+			if err := vm.push(iterator); err != nil {
+				return nil, err
+			}
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			values, ok := vm.stack[vm.sp-1].(*object.Iterator).Next()
+			if !ok {
+				frame.ip = pos - 1
+				break
+			}
+			for _, value := range values {
+				if err := vm.push(value); err != nil {
+					return nil, err
+				}
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			frame.ip += 3
+
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return nil, err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return nil, err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			if vm.framesIndex == 1 {
+				return returnValue, nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(returnValue); err != nil {
+				return nil, err
+			}
+
+		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				return Nil, nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(Nil); err != nil {
+				return nil, err
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown opcode %d at %d", op, ip)
+		}
+	}
+}
+
+var binaryOperators = map[code.Opcode]token.Type{
+	code.OpAdd:                 token.Add,
+	code.OpSubtract:            token.Subtract,
+	code.OpMultiply:            token.Multiply,
+	code.OpDivide:              token.Divide,
+	code.OpModulo:              token.Modulo,
+	code.OpEquals:              token.Equals,
+	code.OpNotEquals:           token.NotEquals,
+	code.OpLessThan:            token.LessThan,
+	code.OpGreaterThan:         token.GreaterThan,
+	code.OpLessThanOrEquals:    token.LessThanOrEquals,
+	code.OpGreaterThanOrEquals: token.GreaterThanOrEquals,
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	result := object.BinaryOperation(binaryOperators[op], left, right)
+	if errObj, ok := result.(*object.Error); ok {
+		return vm.positionedError(errObj)
+	}
+
+	return vm.push(result)
+}
+
+func nativeBoolToBoolean(value bool) object.Boolean {
+	if value {
+		return True
+	}
+	return False
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return vm.newError(object.NotCallableError, "cannot call a value of type %s", callee.Type())
+	}
+}
+
+// callClosure pushes a frame for cl. The arguments already on the stack
+// become its first locals, each in a cell of its own.
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != len(cl.Fn.Parameters) {
+		return vm.newError(object.ArgumentCountError,
+			"function '%s' expects %d arguments, but got %d", cl.Fn.Name, len(cl.Fn.Parameters), numArgs)
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return vm.newError(object.StackOverflowError, "stack overflow")
+	}
+
+	basePointer := vm.sp - numArgs
+	if basePointer+cl.Fn.NumLocals >= StackSize {
+		return vm.newError(object.StackOverflowError, "stack overflow")
+	}
+
+	for i := basePointer; i < vm.sp; i++ {
+		vm.stack[i] = &object.Cell{Value: vm.stack[i]}
+	}
+
+	vm.pushFrame(NewFrame(cl, basePointer))
+	vm.sp = basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(vm.out, args...)
+	if errObj, ok := result.(*object.Error); ok {
+		return vm.positionedError(errObj)
+	}
+
+	vm.sp = vm.sp - numArgs - 1
+
+	return vm.push(result)
+}
+
+func (vm *VM) pushClosure(constIndex, numFree int) error {
+	fn, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", vm.constants[constIndex])
+	}
+
+	free := make([]*object.Cell, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i].(*object.Cell)
+	}
+	vm.sp = vm.sp - numFree
+
+	return vm.push(&object.Closure{Fn: fn, Free: free})
+}
+
+func (vm *VM) push(obj object.Object) error {
+	if vm.sp >= StackSize {
+		return vm.newError(object.StackOverflowError, "stack overflow")
+	}
+
+	vm.stack[vm.sp] = obj
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	obj := vm.stack[vm.sp-1]
+	vm.sp--
+	return obj
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) newError(kind object.ErrorKind, format string, a ...any) error {
+	return vm.positionedError(&object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)})
+}
+
+// positionedError reports errObj at the instruction being executed, with a
+// trace of the calls that led to it.
+func (vm *VM) positionedError(errObj *object.Error) error {
+	frame := vm.currentFrame()
+	if pos, ok := frame.cl.Fn.SourceMap.Lookup(frame.ip); ok {
+		errObj.Line = pos.Line
+		errObj.Col = pos.Col
+	}
+
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		callee := vm.frames[i]
+		caller := vm.frames[i-1]
+
+		pos, _ := caller.cl.Fn.SourceMap.Lookup(caller.ip)
+		errObj.Trace = append(errObj.Trace, object.Frame{Function: callee.cl.Fn.Name, Line: pos.Line, Col: pos.Col})
+	}
+
+	return &object.RuntimeError{Object: errObj}
+}